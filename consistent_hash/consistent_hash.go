@@ -0,0 +1,27 @@
+// Package consistent_hash defines the interface shared by consistent hashing
+// algorithms, so that callers can switch between algorithms without rewriting.
+// Implementations are
+//  1. maglev_hash.MaglevHash, Maglev hash.
+//  2. ring_hash.RingHash, ring hash with virtual nodes.
+//  3. jump_hash.JumpHash, jump consistent hash.
+//  4. rendezvous_hash.RendezvousHash, rendezvous (highest random weight) hash.
+//
+// Example
+// var h consistent_hash.ConsistentHash
+// h, _ = ring_hash.NewRingHash([]string{"B0", "B1"})
+// node := h.Node([]byte("key1"))
+package consistent_hash
+
+// KeyHashFnType hashes a key to an integer. All implementations take a key hash
+// function, CRC32 by default.
+type KeyHashFnType func([]byte) uint32
+
+// ConsistentHash assigns keys to a collection of nodes, and moves as few keys as
+// possible when nodes are added or removed.
+type ConsistentHash interface {
+	// Node returns the assigned node for the given key.
+	Node(key []byte) string
+
+	// Nodes returns the nodes keys are assigned to.
+	Nodes() []string
+}
@@ -1,6 +1,6 @@
 module github.com/pengubco/algorithms
 
-go 1.21.5
+go 1.23
 
 require (
 	github.com/redis/go-redis/v9 v9.2.1
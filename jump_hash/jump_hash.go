@@ -0,0 +1,76 @@
+// Package jump_hash implements the jump consistent hash algorithm from the paper
+// [A Fast, Minimal Memory, Consistent Hash Algorithm](https://arxiv.org/abs/1406.2294)
+// Jump hash maps a key to one of N buckets using O(1) memory and O(logN) time.
+//  1. Load Balance. Each bucket gets 1/N of the keys in expectation.
+//  2. Minimal disruption. Adding a bucket at the end moves 1/(N+1) of the keys,
+//     all to the new bucket.
+//
+// Buckets are numbered, so jump hash only supports adding or removing nodes at
+// the end of the list. Removing a node in the middle shifts all nodes after it
+// and moves many keys. Therefore, nodes are kept in the given order instead of
+// being sorted.
+//
+// Example
+// jh, _ := NewJumpHash([]string{"B0", "B1"})
+// node := jh.Node([]byte("key1"))
+package jump_hash
+
+import (
+	"fmt"
+	"hash/crc32"
+	"slices"
+
+	"github.com/pengubco/algorithms/consistent_hash"
+	"github.com/samber/lo"
+)
+
+var _ consistent_hash.ConsistentHash = (*JumpHash)(nil)
+
+// JumpHash assigns keys to nodes by jump consistent hash.
+// 1. Nodes are identified by string.
+// 2. The default hash function hashing key to the seed of jump hash is CRC32.
+type JumpHash struct {
+	nodes []string
+
+	keyHashFn consistent_hash.KeyHashFnType
+}
+
+// NewJumpHash creates a jump hash for the given nodes, using the CRC32 key hash
+// function.
+func NewJumpHash(nodes []string) (*JumpHash, error) {
+	return NewJumpHashWithKeyHash(nodes, crc32.ChecksumIEEE)
+}
+
+// NewJumpHashWithKeyHash creates a jump hash for the given nodes. Nodes are
+// deduplicated and kept in the given order. The i-th node is the i-th bucket.
+func NewJumpHashWithKeyHash(nodes []string, keyHashFn consistent_hash.KeyHashFnType) (*JumpHash, error) {
+	nodes = lo.Uniq(nodes)
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no node")
+	}
+	return &JumpHash{
+		nodes:     nodes,
+		keyHashFn: keyHashFn,
+	}, nil
+}
+
+// Node returns the assigned node for the given key.
+func (j *JumpHash) Node(key []byte) string {
+	return j.nodes[jump(uint64(j.keyHashFn(key)), len(j.nodes))]
+}
+
+// Nodes returns the nodes in the order of buckets.
+func (j *JumpHash) Nodes() []string {
+	return slices.Clone(j.nodes)
+}
+
+// jump returns the bucket in [0, bucketCnt) for the key.
+func jump(key uint64, bucketCnt int) int {
+	b, j := int64(-1), int64(0)
+	for j < int64(bucketCnt) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
@@ -0,0 +1,50 @@
+package jump_hash_test
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/pengubco/algorithms/jump_hash"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewError(t *testing.T) {
+	_, err := jump_hash.NewJumpHash(nil)
+	assert.Error(t, err)
+}
+
+func TestLoadBalanceAndDisruption(t *testing.T) {
+	keySpaceCnt := 100_000
+	nodes := lo.Times(10, func(i int) string {
+		return fmt.Sprintf("B%d", i)
+	})
+	jh, err := jump_hash.NewJumpHash(nodes)
+	assert.NoError(t, err)
+	assert.Equal(t, nodes, jh.Nodes())
+
+	keyToNode1 := make(map[int]string)
+	load := make(map[string]int)
+	for i := 0; i < keySpaceCnt; i++ {
+		node := jh.Node([]byte(strconv.Itoa(i)))
+		keyToNode1[i] = node
+		load[node]++
+	}
+	assert.Len(t, load, 10)
+	for _, v := range load {
+		assert.InDelta(t, keySpaceCnt/10, v, float64(keySpaceCnt)/10*0.1)
+	}
+
+	// adding a node at the end only moves keys to that node.
+	jh, err = jump_hash.NewJumpHash(append(nodes, "B10"))
+	assert.NoError(t, err)
+	moveCnt := 0
+	for i := 0; i < keySpaceCnt; i++ {
+		if node := jh.Node([]byte(strconv.Itoa(i))); node != keyToNode1[i] {
+			assert.Equal(t, "B10", node)
+			moveCnt++
+		}
+	}
+	assert.InDelta(t, keySpaceCnt/11, moveCnt, float64(keySpaceCnt)/11*0.1)
+}
@@ -1,144 +1,335 @@
 package main
 
 import (
+	"encoding/binary"
+	"encoding/csv"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"hash/crc32"
+	"io"
 	"log"
+	"math"
+	"os"
 	"strconv"
+	"strings"
 
+	"github.com/pengubco/algorithms/consistent_hash"
+	"github.com/pengubco/algorithms/jump_hash"
 	"github.com/pengubco/algorithms/maglev_hash"
+	"github.com/pengubco/algorithms/prime"
+	"github.com/pengubco/algorithms/rendezvous_hash"
+	"github.com/pengubco/algorithms/ring_hash"
 	"github.com/samber/lo"
 )
 
-// This program writes out four lines.
-// 1. number of nodes
-// 2. number of slots
-// 3: number of slots assigned to each node
-// 4: number of slots that need to be redistributed after removing a node.
+// This program compares consistent hashing algorithms. It runs an experiment for
+// each combination of algorithm, scenario, number of nodes and number of slots,
+// and writes one result per experiment, in CSV or JSON.
+//
+// In each experiment, nodes are named "0", "1", ..., keys are hashed to nodes
+// before and after a membership change, and the result has
+//  1. Statistics of the number of keys per node before the change.
+//  2. The node added, removed or reweighted by the change.
+//  3. Disruption ratio, the ratio of keys moved by the change, and the minimal
+//     disruption ratio any algorithm has to pay for the change.
+//
+// Load and disruption are exact where the algorithm allows it, because with
+// large tables the differences between tables are far smaller than the noise of
+// sampling keys. Maglev hash takes the share of slots each node owns and the
+// ratio of slots moved by the change. Ring hash takes the share of the ring each
+// node owns, but still samples keys for disruption. Jump hash and rendezvous
+// hash sample keyCnt keys for both. The number of keys per node is the share of
+// a node times keyCnt.
+//
+// Scenarios are
+//  1. add: add node "N" to nodes "0" to "N-1".
+//  2. remove: remove node "N/2". Jump hash removes node "N-1" instead, because
+//     it only supports removing the last bucket. Removing a node in the middle
+//     renumbers every bucket after it, which measures the renumbering rather
+//     than the algorithm.
+//  3. weight: double the weight of node "0". Only Maglev hash supports weights.
+//
+// Slots only apply to Maglev hash and ring hash. Maglev hash has slotCnt slots,
+// and ring hash has slotCnt/nodeCnt virtual nodes per node, so that both use a
+// table of about slotCnt entries.
+//
+// Example
+// go run ./maglev_hash/analysis -nodeCnts=10,100 -slotCnts=1009,10007 -format=csv
 func main() {
-	var nodeCnt int
-	var slotCnt int
-	flag.IntVar(&nodeCnt, "nodeCnt", 0, "number of nodes")
-	flag.IntVar(&slotCnt, "slotCnt", 0, "number of slots. must be a prime number and larger than nodeCnt")
+	var nodeCnts, slotCnts, algorithms, scenarios, format string
+	var keyCnt int
+	flag.StringVar(&nodeCnts, "nodeCnts", "10", "comma separated numbers of nodes")
+	flag.StringVar(&slotCnts, "slotCnts", "10007", "comma separated numbers of slots. each is rounded up to a prime number")
+	flag.StringVar(&algorithms, "algorithms", "maglev", "comma separated algorithms: maglev, ring, jump, rendezvous")
+	flag.StringVar(&scenarios, "scenarios", "add,remove,weight", "comma separated scenarios: add, remove, weight")
+	flag.IntVar(&keyCnt, "keyCnt", 100_000, "number of keys hashed in each experiment")
+	flag.StringVar(&format, "format", "csv", "output format: csv or json")
 	flag.Parse()
 
-	e, err := NewExperiment(nodeCnt, slotCnt)
+	experiments, err := newExperiments(nodeCnts, slotCnts, algorithms, scenarios, keyCnt)
 	if err != nil {
 		log.Fatal(err)
 	}
+	results := make([]Result, 0, len(experiments))
+	for _, e := range experiments {
+		result, err := e.run()
+		if err != nil {
+			log.Fatal(err)
+		}
+		results = append(results, result)
+	}
+	if err := writeResults(os.Stdout, format, results); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Experiment measures load balance and disruption of one algorithm in one scenario.
+type Experiment struct {
+	Algorithm string `json:"algorithm"`
+	Scenario  string `json:"scenario"`
+	NodeCnt   int    `json:"nodeCnt"`
+	SlotCnt   int    `json:"slotCnt"`
+	KeyCnt    int    `json:"keyCnt"`
+}
+
+// Result is the result of an experiment.
+type Result struct {
+	Experiment
 
-	fmt.Println(nodeCnt)
-	fmt.Println(slotCnt)
+	// The node added, removed or reweighted by the membership change.
+	ChangedNode string `json:"changedNode"`
 
-	slotToNode := e.getSlotAssignment()
-	nodeLoads := lo.Values(calculateNodeLoads(slotToNode))
-	b, _ := json.Marshal(nodeLoads)
-	fmt.Printf("%s\n", string(b))
+	// Statistics of the number of keys per node before the membership change.
+	MinLoad    float64 `json:"minLoad"`
+	MaxLoad    float64 `json:"maxLoad"`
+	MeanLoad   float64 `json:"meanLoad"`
+	StddevLoad float64 `json:"stddevLoad"`
+	// Coefficient of variation, StddevLoad / MeanLoad.
+	CV float64 `json:"cv"`
 
-	e.removeNode("1")
-	newSlotToNode := e.getSlotAssignment()
-	slotMoved := calculateSlotMove(slotToNode, newSlotToNode)
-	fmt.Printf("%d\n", slotMoved)
+	// Ratio of keys moved by the membership change.
+	DisruptionRatio float64 `json:"disruptionRatio"`
+	// The minimal ratio of keys that must move for the change.
+	MinDisruptionRatio float64 `json:"minDisruptionRatio"`
+	// DisruptionRatio / MinDisruptionRatio - 1.
+	DisruptionOverhead float64 `json:"disruptionOverhead"`
 }
 
-func calculateNodeLoads(slotToNode map[int]int) map[int]int {
-	load := make(map[int]int)
-	for _, v := range slotToNode {
-		load[v]++
+// newExperiments returns experiments of all combinations of the comma separated
+// flags. Combinations an algorithm does not support are skipped, and algorithms
+// without slots run once for each number of nodes.
+func newExperiments(nodeCnts, slotCnts, algorithms, scenarios string, keyCnt int) ([]Experiment, error) {
+	nodeCntList, err := parseInts(nodeCnts)
+	if err != nil {
+		return nil, err
 	}
-	return load
-}
+	slotCntList, err := parseInts(slotCnts)
+	if err != nil {
+		return nil, err
+	}
+	slotCntList = lo.Uniq(lo.Map(slotCntList, func(n int, _ int) int { return prime.NextPrime(n - 1) }))
 
-// calculateSlotMove returns number of slots that are redistributed.
-func calculateSlotMove(a, b map[int]int) int {
-	result := 0
-	for k, _ := range a {
-		if a[k] != b[k] {
-			result++
+	var experiments []Experiment
+	for _, algorithm := range strings.Split(algorithms, ",") {
+		if !lo.Contains([]string{"maglev", "ring", "jump", "rendezvous"}, algorithm) {
+			return nil, fmt.Errorf("unknown algorithm, %s", algorithm)
+		}
+		for _, scenario := range strings.Split(scenarios, ",") {
+			if !lo.Contains([]string{"add", "remove", "weight"}, scenario) {
+				return nil, fmt.Errorf("unknown scenario, %s", scenario)
+			}
+			if scenario == "weight" && algorithm != "maglev" {
+				continue
+			}
+			for _, nodeCnt := range nodeCntList {
+				if nodeCnt < 2 {
+					return nil, fmt.Errorf("number of nodes must be at least 2, %d", nodeCnt)
+				}
+				experimentSlotCnts := slotCntList
+				if algorithm == "jump" || algorithm == "rendezvous" {
+					experimentSlotCnts = []int{0}
+				}
+				for _, slotCnt := range experimentSlotCnts {
+					experiments = append(experiments, Experiment{
+						Algorithm: algorithm,
+						Scenario:  scenario,
+						NodeCnt:   nodeCnt,
+						SlotCnt:   slotCnt,
+						KeyCnt:    keyCnt,
+					})
+				}
+			}
 		}
 	}
-	return result
+	return experiments, nil
 }
 
-func sanityCheck() {
-	e, err := NewExperiment(3, 7)
+func (e Experiment) run() (Result, error) {
+	weights := lo.Times(e.NodeCnt, func(int) int { return 1 })
+	before, err := e.newHash(weights)
 	if err != nil {
-		log.Fatal(err)
+		return Result{}, err
+	}
+	var minDisruption float64
+	var changed int
+	n := float64(e.NodeCnt)
+	switch e.Scenario {
+	case "add":
+		changed = e.NodeCnt
+		weights = append(weights, 1)
+		minDisruption = 1 / (n + 1)
+	case "remove":
+		changed = e.NodeCnt / 2
+		if e.Algorithm == "jump" {
+			changed = e.NodeCnt - 1
+		}
+		weights[changed] = 0
+		minDisruption = 1 / n
+	case "weight":
+		changed = 0
+		weights[changed] = 2
+		minDisruption = 2/(n+1) - 1/n
+	}
+	after, err := e.newHash(weights)
+	if err != nil {
+		return Result{}, err
+	}
+
+	shares, err := e.shares(before)
+	if err != nil {
+		return Result{}, err
+	}
+	loads := lo.Map(before.Nodes(), func(node string, _ int) float64 {
+		return shares[node] * float64(e.KeyCnt)
+	})
+	disruption, err := e.disruption(before, after)
+	if err != nil {
+		return Result{}, err
 	}
-	slotToNode := e.getSlotAssignment()
-	fmt.Printf("%+v\n", slotToNode)
 
-	e.removeNode("1")
-	slotToNode2 := e.getSlotAssignment()
-	fmt.Printf("%+v\n", slotToNode2)
+	r := Result{
+		Experiment:         e,
+		ChangedNode:        strconv.Itoa(changed),
+		MinLoad:            lo.Min(loads),
+		MaxLoad:            lo.Max(loads),
+		MeanLoad:           lo.Sum(loads) / float64(len(loads)),
+		DisruptionRatio:    disruption,
+		MinDisruptionRatio: minDisruption,
+	}
+	for _, v := range loads {
+		r.StddevLoad += (v - r.MeanLoad) * (v - r.MeanLoad)
+	}
+	r.StddevLoad = math.Sqrt(r.StddevLoad / float64(len(loads)))
+	r.CV = r.StddevLoad / r.MeanLoad
+	r.DisruptionOverhead = r.DisruptionRatio/r.MinDisruptionRatio - 1
+	return r, nil
 }
 
-// Experiment has the following settings.
-//  1. Nodes: Nodes are number 0, 1, 2, ...
-//  2. Use Atoi as the key-hash function which returns the integer form a string.
-//     So that we can use Node([]byte("1")) to get the node assigned for slot-1.
-type Experiment struct {
-	nodeCnt int
-	slotCnt int
-	nodes   []string
+// shares returns the ratio of keys each node gets.
+func (e Experiment) shares(h consistent_hash.ConsistentHash) (map[string]float64, error) {
+	switch h := h.(type) {
+	case *maglev_hash.MaglevHash:
+		return lo.MapValues(h.SlotOwnership(), func(slots []int, _ string) float64 {
+			return float64(len(slots)) / float64(h.SlotCnt())
+		}), nil
+	case *ring_hash.RingHash:
+		return h.ArcShares(), nil
+	}
+	shares := make(map[string]float64)
+	key := make([]byte, 8)
+	for i := 0; i < e.KeyCnt; i++ {
+		binary.BigEndian.PutUint64(key, uint64(i))
+		shares[h.Node(key)] += 1 / float64(e.KeyCnt)
+	}
+	return shares, nil
+}
 
-	mh *maglev_hash.MaglevHash
+// disruption returns the ratio of keys moved from the before hash to the after
+// hash.
+func (e Experiment) disruption(before, after consistent_hash.ConsistentHash) (float64, error) {
+	if b, ok := before.(*maglev_hash.MaglevHash); ok {
+		d, err := maglev_hash.Diff(b, after.(*maglev_hash.MaglevHash))
+		if err != nil {
+			return 0, err
+		}
+		return d.MoveRatio(), nil
+	}
+	moveCnt := 0
+	key := make([]byte, 8)
+	for i := 0; i < e.KeyCnt; i++ {
+		binary.BigEndian.PutUint64(key, uint64(i))
+		if before.Node(key) != after.Node(key) {
+			moveCnt++
+		}
+	}
+	return float64(moveCnt) / float64(e.KeyCnt), nil
 }
 
-func NewExperiment(nodeCnt int, slotCnt int) (*Experiment, error) {
-	nodes := lo.Times(nodeCnt, func(i int) string {
-		return fmt.Sprintf("%d", i)
-	})
-	mh, err := maglev_hash.NewMaglevWithTableSize(slotCnt, nodes, func(b []byte) uint32 {
-		i, _ := strconv.Atoi(string(b))
-		return uint32(i)
+// newHash creates the hash of the experiment's algorithm for nodes "0", "1", ...
+// weights[i] is the weight of node "i", and nodes of weight 0 are left out.
+func (e Experiment) newHash(weights []int) (consistent_hash.ConsistentHash, error) {
+	nodeWeights := make(map[string]int)
+	for i, w := range weights {
+		if w > 0 {
+			nodeWeights[strconv.Itoa(i)] = w
+		}
+	}
+	// keep nodes in the order of numbers, which jump hash depends on.
+	nodes := lo.FilterMap(weights, func(w int, i int) (string, bool) {
+		return strconv.Itoa(i), w > 0
 	})
-	if err != nil {
-		return nil, err
+	switch e.Algorithm {
+	case "maglev":
+		return maglev_hash.NewWeightedMaglevWithTableSize(e.SlotCnt, nodeWeights, crc32.ChecksumIEEE)
+	case "ring":
+		return ring_hash.NewRingHashWithVirtualNodes(max(1, e.SlotCnt/e.NodeCnt), nodes, crc32.ChecksumIEEE)
+	case "jump":
+		return jump_hash.NewJumpHash(nodes)
+	case "rendezvous":
+		return rendezvous_hash.NewRendezvousHash(nodes)
 	}
-	return &Experiment{
-		nodeCnt: nodeCnt,
-		slotCnt: slotCnt,
-		nodes:   nodes,
-		mh:      mh,
-	}, nil
+	return nil, fmt.Errorf("unknown algorithm, %s", e.Algorithm)
 }
 
-// returns slot -> node mapping.
-func (e *Experiment) getSlotAssignment() map[int]int {
-	result := make(map[int]int)
-	for i := 0; i < e.slotCnt; i++ {
-		nodeStr := e.mh.Node([]byte(strconv.Itoa(i)))
-		nodeID, _ := strconv.Atoi(nodeStr)
-		result[i] = nodeID
+func writeResults(w io.Writer, format string, results []Result) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	case "csv":
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{
+			"algorithm", "scenario", "nodeCnt", "slotCnt", "keyCnt", "changedNode",
+			"minLoad", "maxLoad", "meanLoad", "stddevLoad", "cv",
+			"disruptionRatio", "minDisruptionRatio", "disruptionOverhead",
+		})
+		for _, r := range results {
+			_ = cw.Write([]string{
+				r.Algorithm, r.Scenario, strconv.Itoa(r.NodeCnt), strconv.Itoa(r.SlotCnt), strconv.Itoa(r.KeyCnt), r.ChangedNode,
+				formatFloat(r.MinLoad), formatFloat(r.MaxLoad), formatFloat(r.MeanLoad), formatFloat(r.StddevLoad), formatFloat(r.CV),
+				formatFloat(r.DisruptionRatio), formatFloat(r.MinDisruptionRatio), formatFloat(r.DisruptionOverhead),
+			})
+		}
+		cw.Flush()
+		return cw.Error()
 	}
-	return result
+	return fmt.Errorf("unknown format, %s", format)
 }
 
-func (e *Experiment) removeNode(node string) error {
-	found := false
-	newNodes := make([]string, 0)
-	for _, v := range e.nodes {
-		if v != node {
-			newNodes = append(newNodes, v)
-		} else {
-			found = true
+func parseInts(s string) ([]int, error) {
+	var result []int
+	for _, v := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return nil, err
 		}
+		result = append(result, n)
 	}
-	if !found {
-		return fmt.Errorf("node does not exist, %s", node)
-	}
-	e.nodeCnt = len(newNodes)
-	e.nodes = newNodes
+	return result, nil
+}
 
-	mh, err := maglev_hash.NewMaglevWithTableSize(e.slotCnt, e.nodes, func(b []byte) uint32 {
-		i, _ := strconv.Atoi(string(b))
-		return uint32(i)
-	})
-	if err != nil {
-		return err
-	}
-	e.mh = mh
-	return nil
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 6, 64)
 }
@@ -0,0 +1,172 @@
+package maglev_hash
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pengubco/algorithms/consistent_hash"
+)
+
+var _ consistent_hash.ConsistentHash = (*Atomic)(nil)
+
+// Atomic holds a MaglevHash that is safe for concurrent use. Lookups are
+// lock-free and served by the current table. A new table is published with an
+// atomic pointer swap, and each published table is tagged with a version that
+// increases by one on every publish. Subscribers are notified of every publish
+// with the slots that changed owners.
+//
+// Example
+// a := NewAtomic(mh)
+// node, version := a.NodeWithVersion([]byte("key1"))
+// a.Subscribe(func(c Change) { invalidate(c.Diff) })
+// a.Update(func(mh *MaglevHash) (*MaglevHash, error) { return mh.AddNode("B2") })
+type Atomic struct {
+	current atomic.Pointer[versionedMaglev]
+
+	// mu serializes writers so that versions are published in order. It also
+	// guards subscribers.
+	mu sync.Mutex
+
+	subscribers []subscriber
+
+	// id of the next subscriber.
+	nextSubscriberID int
+}
+
+// Change is a publish of a new table in Atomic.
+type Change struct {
+	OldVersion uint64
+	NewVersion uint64
+
+	Old *MaglevHash
+	New *MaglevHash
+
+	// Diff has the slots whose owners changed, with the owner before and after
+	// the change. It is nil if the two tables have different numbers of slots,
+	// e.g. after Regrow, in which case any key may have moved.
+	Diff *TableDiff
+}
+
+type subscriber struct {
+	id int
+	fn func(Change)
+}
+
+// versionedMaglev is a MaglevHash and the version it was published with.
+type versionedMaglev struct {
+	mh      *MaglevHash
+	version uint64
+}
+
+// NewAtomic creates an Atomic serving the given Maglev hash as version 1. It
+// panics if mh is nil, which would otherwise panic every lookup.
+func NewAtomic(mh *MaglevHash) *Atomic {
+	if mh == nil {
+		panic("maglev_hash: NewAtomic with nil MaglevHash")
+	}
+	a := &Atomic{}
+	a.current.Store(&versionedMaglev{mh: mh, version: 1})
+	return a
+}
+
+// Node returns the assigned node for the given key in the current table.
+func (a *Atomic) Node(key []byte) string {
+	return a.current.Load().mh.Node(key)
+}
+
+// Nodes returns the nodes of the current table.
+func (a *Atomic) Nodes() []string {
+	return a.current.Load().mh.Nodes()
+}
+
+// NodeWithVersion returns the assigned node for the given key and the version
+// of the table that answered the lookup.
+func (a *Atomic) NodeWithVersion(key []byte) (string, uint64) {
+	v := a.current.Load()
+	return v.mh.Node(key), v.version
+}
+
+// Load returns the current Maglev hash and its version.
+func (a *Atomic) Load() (*MaglevHash, uint64) {
+	v := a.current.Load()
+	return v.mh, v.version
+}
+
+// Version returns the version of the current table.
+func (a *Atomic) Version() uint64 {
+	return a.current.Load().version
+}
+
+// Store publishes the given Maglev hash and returns its version. It panics if
+// mh is nil, which would otherwise panic every lookup.
+func (a *Atomic) Store(mh *MaglevHash) uint64 {
+	if mh == nil {
+		panic("maglev_hash: Store of nil MaglevHash")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.publish(mh)
+}
+
+// Update builds a new table from the current one with fn and publishes it.
+// Concurrent updates are serialized, so fn always sees the latest table. If fn
+// returns error or a nil table, the current table is kept and an error is
+// returned.
+func (a *Atomic) Update(fn func(mh *MaglevHash) (*MaglevHash, error)) (uint64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	v := a.current.Load()
+	mh, err := fn(v.mh)
+	if err != nil {
+		return v.version, err
+	}
+	if mh == nil {
+		return v.version, errors.New("update returned nil maglev hash")
+	}
+	return a.publish(mh), nil
+}
+
+// Subscribe registers fn to be notified after each publish, and returns a
+// function that unregisters it. Subscribers are called synchronously in the
+// order they subscribed, by the goroutine that publishes, so changes are
+// delivered in the order of versions and a publish returns after all
+// subscribers return. fn must not call Store, Update, or Subscribe on a.
+func (a *Atomic) Subscribe(fn func(Change)) (unsubscribe func()) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	id := a.nextSubscriberID
+	a.nextSubscriberID++
+	a.subscribers = append(a.subscribers, subscriber{id: id, fn: fn})
+	return func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		a.subscribers = slices.DeleteFunc(a.subscribers, func(s subscriber) bool {
+			return s.id == id
+		})
+	}
+}
+
+// publish swaps in the given table with the next version and notifies
+// subscribers. Caller must hold mu.
+func (a *Atomic) publish(mh *MaglevHash) uint64 {
+	old := a.current.Load()
+	version := old.version + 1
+	a.current.Store(&versionedMaglev{mh: mh, version: version})
+	if len(a.subscribers) == 0 {
+		return version
+	}
+	c := Change{
+		OldVersion: old.version,
+		NewVersion: version,
+		Old:        old.mh,
+		New:        mh,
+	}
+	// Diff only fails on different numbers of slots, which leaves c.Diff nil.
+	c.Diff, _ = Diff(old.mh, mh)
+	for _, s := range a.subscribers {
+		s.fn(c)
+	}
+	return version
+}
@@ -0,0 +1,143 @@
+package maglev_hash_test
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/pengubco/algorithms/maglev_hash"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAtomic(t *testing.T) {
+	mh, err := maglev_hash.NewMaglevWithTableSize(7, []string{"B0", "B1"}, crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+	a := maglev_hash.NewAtomic(mh)
+	assert.Equal(t, uint64(1), a.Version())
+	node, version := a.NodeWithVersion([]byte("key1"))
+	assert.Equal(t, mh.Node([]byte("key1")), node)
+	assert.Equal(t, uint64(1), version)
+
+	mh2, err := maglev_hash.NewMaglevWithTableSize(7, []string{"B2"}, crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), a.Store(mh2))
+	assert.Equal(t, "B2", a.Node([]byte("key1")))
+
+	version, err = a.Update(func(mh *maglev_hash.MaglevHash) (*maglev_hash.MaglevHash, error) {
+		return nil, errors.New("failed to build")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, uint64(2), version)
+	current, version := a.Load()
+	assert.Same(t, mh2, current)
+	assert.Equal(t, uint64(2), version)
+
+	// nil tables are rejected, and the current table is kept.
+	version, err = a.Update(func(mh *maglev_hash.MaglevHash) (*maglev_hash.MaglevHash, error) {
+		return nil, nil
+	})
+	assert.Error(t, err)
+	assert.Equal(t, uint64(2), version)
+	assert.Panics(t, func() { a.Store(nil) })
+	assert.Panics(t, func() { maglev_hash.NewAtomic(nil) })
+	assert.Equal(t, uint64(2), a.Version())
+	assert.Equal(t, "B2", a.Node([]byte("key1")))
+}
+
+func TestAtomic_ConcurrentLookupAndUpdate(t *testing.T) {
+	nodes := lo.Times(10, func(i int) string {
+		return fmt.Sprintf("B%d", i)
+	})
+	mh, err := maglev_hash.NewMaglevWithTableSize(1009, nodes, crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+	a := maglev_hash.NewAtomic(mh)
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var lastVersion uint64
+			for j := 0; ; j++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				node, version := a.NodeWithVersion([]byte(strconv.Itoa(j)))
+				assert.NotEmpty(t, node)
+				assert.GreaterOrEqual(t, version, lastVersion)
+				lastVersion = version
+			}
+		}()
+	}
+
+	for i := 10; i < 30; i++ {
+		node := fmt.Sprintf("B%d", i)
+		_, err := a.Update(func(mh *maglev_hash.MaglevHash) (*maglev_hash.MaglevHash, error) {
+			return mh.AddNode(node)
+		})
+		assert.NoError(t, err)
+	}
+	close(done)
+	wg.Wait()
+	assert.Equal(t, uint64(21), a.Version())
+}
+
+func TestAtomic_Subscribe(t *testing.T) {
+	nodes := lo.Times(10, func(i int) string {
+		return fmt.Sprintf("B%d", i)
+	})
+	mh, err := maglev_hash.NewMaglevWithTableSize(1009, nodes, crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+	a := maglev_hash.NewAtomic(mh)
+
+	var changes1, changes2 []maglev_hash.Change
+	unsubscribe1 := a.Subscribe(func(c maglev_hash.Change) {
+		changes1 = append(changes1, c)
+	})
+	a.Subscribe(func(c maglev_hash.Change) {
+		changes2 = append(changes2, c)
+	})
+
+	_, err = a.Update(func(mh *maglev_hash.MaglevHash) (*maglev_hash.MaglevHash, error) {
+		return mh.RemoveNode("B5")
+	})
+	assert.NoError(t, err)
+	assert.Len(t, changes1, 1)
+	assert.Equal(t, changes1, changes2)
+	c := changes1[0]
+	assert.Equal(t, uint64(1), c.OldVersion)
+	assert.Equal(t, uint64(2), c.NewVersion)
+	assert.Same(t, mh, c.Old)
+	current, _ := a.Load()
+	assert.Same(t, current, c.New)
+	expected, err := maglev_hash.Diff(mh, current)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, c.Diff)
+	assert.Equal(t, c.Diff.Lost["B5"], lo.CountBy(c.Diff.Moves, func(m maglev_hash.SlotMove) bool {
+		return m.From == "B5"
+	}))
+
+	// a failed update is not published.
+	_, err = a.Update(func(mh *maglev_hash.MaglevHash) (*maglev_hash.MaglevHash, error) {
+		return mh.RemoveNode("B5")
+	})
+	assert.Error(t, err)
+	assert.Len(t, changes1, 1)
+
+	// tables of different numbers of slots have no diff.
+	unsubscribe1()
+	resized, err := maglev_hash.NewMaglevWithTableSize(7, nodes[:2], crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+	a.Store(resized)
+	assert.Len(t, changes1, 1)
+	assert.Len(t, changes2, 2)
+	assert.Nil(t, changes2[1].Diff)
+	assert.Equal(t, uint64(3), changes2[1].NewVersion)
+}
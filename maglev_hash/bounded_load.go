@@ -0,0 +1,144 @@
+package maglev_hash
+
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+
+	"github.com/samber/lo"
+)
+
+// BoundedLoadMaglev implements consistent hashing with bounded loads
+// (https://arxiv.org/abs/1608.01350) on top of a MaglevHash. Callers report the
+// in-flight load of each node. A node is full when its load reaches
+// (1+epsilon) times its share of the total load, the share being proportional to
+// its weight. Keys of a full node spill to the next node that is not full, in
+// the order of NodesN. It is safe for concurrent use. Loads are updated
+// atomically but not together with lookups, so under concurrency the bound is
+// best-effort.
+//
+// Example
+// b, _ := NewBoundedLoadMaglev(mh, 0.25)
+// node := b.Acquire([]byte("key1"))
+// defer b.Release(node)
+type BoundedLoadMaglev struct {
+	mh *MaglevHash
+
+	epsilon float64
+
+	// weights[i] is the weight of node[i], and totalWeight is the sum of weights.
+	weights     []int
+	totalWeight int
+
+	// loads[i] is the in-flight load of node[i].
+	loads []atomic.Int64
+
+	// sum of loads.
+	totalLoad atomic.Int64
+}
+
+// NewBoundedLoadMaglev creates a BoundedLoadMaglev where all nodes have no load.
+// epsilon must be positive. The smaller epsilon, the more even the load and the
+// more keys spill.
+func NewBoundedLoadMaglev(mh *MaglevHash, epsilon float64) (*BoundedLoadMaglev, error) {
+	if epsilon <= 0 {
+		return nil, fmt.Errorf("epsilon must be positive, %f", epsilon)
+	}
+	weights := mh.nodeWeights()
+	return &BoundedLoadMaglev{
+		mh:          mh,
+		epsilon:     epsilon,
+		weights:     weights,
+		totalWeight: lo.Sum(weights),
+		loads:       make([]atomic.Int64, mh.nodeCnt),
+	}, nil
+}
+
+// Table returns the underlying Maglev hash.
+func (b *BoundedLoadMaglev) Table() *MaglevHash {
+	return b.mh
+}
+
+// Node returns the first node, in the order of NodesN, that can take one more
+// load without exceeding its bound. Node does not change loads.
+func (b *BoundedLoadMaglev) Node(key []byte) string {
+	return b.mh.nodes[b.node(key)]
+}
+
+// Acquire returns the node for the given key as Node does, and adds one load to
+// it. Callers must call Release with the node when the work is done.
+func (b *BoundedLoadMaglev) Acquire(key []byte) string {
+	i := b.node(key)
+	b.loads[i].Add(1)
+	b.totalLoad.Add(1)
+	return b.mh.nodes[i]
+}
+
+// Release removes one load from the node. It returns error if the node does
+// not exist or has no load, e.g. when Release is called more times than Acquire.
+func (b *BoundedLoadMaglev) Release(node string) error {
+	i, ok := b.mh.nodeIndex(node)
+	if !ok {
+		return fmt.Errorf("node does not exist, %s", node)
+	}
+	for {
+		load := b.loads[i].Load()
+		if load <= 0 {
+			return fmt.Errorf("node has no load, %s", node)
+		}
+		if b.loads[i].CompareAndSwap(load, load-1) {
+			break
+		}
+	}
+	b.totalLoad.Add(-1)
+	return nil
+}
+
+// SetLoad sets the load of the node, e.g. from the number of in-flight requests
+// reported by the node. It returns error if the node does not exist or the load
+// is negative.
+func (b *BoundedLoadMaglev) SetLoad(node string, load int64) error {
+	i, ok := b.mh.nodeIndex(node)
+	if !ok {
+		return fmt.Errorf("node does not exist, %s", node)
+	}
+	if load < 0 {
+		return fmt.Errorf("load must not be negative, %d", load)
+	}
+	old := b.loads[i].Swap(load)
+	b.totalLoad.Add(load - old)
+	return nil
+}
+
+// Load returns the load of the node, 0 if the node does not exist.
+func (b *BoundedLoadMaglev) Load(node string) int64 {
+	i, ok := b.mh.nodeIndex(node)
+	if !ok {
+		return 0
+	}
+	return b.loads[i].Load()
+}
+
+// node returns the index of the first node that is not full. It falls back to
+// the owner of the key's slot if all nodes are full, which only happens when
+// loads change concurrently.
+func (b *BoundedLoadMaglev) node(key []byte) int {
+	slot := b.mh.Slot(key)
+	// the bound counts the load about to be added.
+	total := float64(b.totalLoad.Load() + 1)
+	result := b.mh.lookup.at(slot)
+	b.mh.walk(slot, func(i int) bool {
+		if b.loads[i].Load()+1 > b.capacity(i, total) {
+			return true
+		}
+		result = i
+		return false
+	})
+	return result
+}
+
+// capacity returns the max load of node[i] given the total load.
+func (b *BoundedLoadMaglev) capacity(i int, total float64) int64 {
+	share := total * float64(b.weights[i]) / float64(b.totalWeight)
+	return int64(math.Ceil((1 + b.epsilon) * share))
+}
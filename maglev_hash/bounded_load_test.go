@@ -0,0 +1,71 @@
+package maglev_hash_test
+
+import (
+	"fmt"
+	"hash/crc32"
+	"math"
+	"testing"
+
+	"github.com/pengubco/algorithms/maglev_hash"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBoundedLoadMaglev(t *testing.T) {
+	nodes := lo.Times(10, func(i int) string {
+		return fmt.Sprintf("B%d", i)
+	})
+	mh, err := maglev_hash.NewMaglevWithTableSize(1009, nodes, crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+
+	_, err = maglev_hash.NewBoundedLoadMaglev(mh, 0)
+	assert.Error(t, err)
+	b, err := maglev_hash.NewBoundedLoadMaglev(mh, 0.25)
+	assert.NoError(t, err)
+	assert.Same(t, mh, b.Table())
+
+	// without load, keys go to their owners.
+	key := []byte("hot key")
+	assert.Equal(t, mh.Node(key), b.Node(key))
+
+	// a single hot key spreads over nodes, and no node exceeds (1+epsilon) of the average.
+	total := 1000
+	for i := 0; i < total; i++ {
+		b.Acquire(key)
+	}
+	for _, node := range nodes {
+		assert.LessOrEqual(t, b.Load(node), int64(math.Ceil(1.25*float64(total)/10)))
+	}
+	assert.Equal(t, int64(total), lo.Sum(lo.Map(nodes, func(node string, _ int) int64 { return b.Load(node) })))
+
+	// the owner is preferred once it has room.
+	owner := mh.Node(key)
+	assert.NoError(t, b.SetLoad(owner, 0))
+	assert.Equal(t, owner, b.Node(key))
+	assert.Error(t, b.Release(owner))
+	assert.Zero(t, b.Load(owner))
+	assert.NoError(t, b.SetLoad(owner, 1))
+	assert.NoError(t, b.Release(owner))
+	assert.Zero(t, b.Load(owner))
+	assert.Error(t, b.SetLoad(owner, -1))
+
+	assert.Error(t, b.Release("B10"))
+	assert.Error(t, b.SetLoad("B10", 1))
+	assert.Zero(t, b.Load("B10"))
+}
+
+func TestBoundedLoadMaglev_Weighted(t *testing.T) {
+	weights := map[string]int{"B0": 1, "B1": 3}
+	mh, err := maglev_hash.NewWeightedMaglevWithTableSize(1009, weights, crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+	b, err := maglev_hash.NewBoundedLoadMaglev(mh, 0.25)
+	assert.NoError(t, err)
+
+	key := []byte("hot key")
+	for i := 0; i < 1000; i++ {
+		b.Acquire(key)
+	}
+	// each node is bounded by its share of the load.
+	assert.LessOrEqual(t, b.Load("B0"), int64(math.Ceil(1.25*250)))
+	assert.LessOrEqual(t, b.Load("B1"), int64(math.Ceil(1.25*750)))
+}
@@ -0,0 +1,301 @@
+// Command lb is an HTTP reverse proxy that routes requests to backends by
+// Maglev hash. A request is hashed by the value of a configurable header, or by
+// the client IP if the header is not configured or missing from the request.
+// Backends are read from a JSON config file, which is reloaded when it changes.
+// Per-backend counters are served as JSON on the admin address.
+//
+// Example config
+//
+//	{
+//	  "slotCnt": 10007,
+//	  "hashHeader": "X-User-ID",
+//	  "backends": [
+//	    {"name": "b0", "url": "http://127.0.0.1:9001", "weight": 1},
+//	    {"name": "b1", "url": "http://127.0.0.1:9002", "weight": 2}
+//	  ]
+//	}
+//
+// Usage
+// go run ./maglev_hash/cmd/lb -config lb.json -listen :8080 -admin :8081
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pengubco/algorithms/maglev_hash"
+)
+
+func main() {
+	var configPath, listenAddr, adminAddr string
+	var reloadInterval time.Duration
+	flag.StringVar(&configPath, "config", "lb.json", "path of the config file")
+	flag.StringVar(&listenAddr, "listen", ":8080", "address to serve proxied requests")
+	flag.StringVar(&adminAddr, "admin", ":8081", "address to serve per-backend counters")
+	flag.DurationVar(&reloadInterval, "reload", 5*time.Second, "interval of checking the config file for changes")
+	flag.Parse()
+
+	lb, err := newLoadBalancerFromFile(configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	go lb.WatchConfig(context.Background(), configPath, reloadInterval)
+	go func() {
+		log.Fatal(http.ListenAndServe(adminAddr, http.HandlerFunc(lb.ServeStats)))
+	}()
+	log.Fatal(http.ListenAndServe(listenAddr, lb))
+}
+
+// Config is the content of the config file.
+type Config struct {
+	// Number of slots, must be a prime number. The default is maglev_hash.DefaultSlotCnt.
+	SlotCnt int `json:"slotCnt"`
+
+	// The header to hash requests by. Empty means hashing by client IP.
+	HashHeader string `json:"hashHeader"`
+
+	Backends []BackendConfig `json:"backends"`
+}
+
+// BackendConfig is a backend in the config file.
+type BackendConfig struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Weight must be positive. The default is 1.
+	Weight int `json:"weight"`
+}
+
+// LoadBalancer is an http.Handler that proxies each request to the backend the
+// request's key is assigned to.
+type LoadBalancer struct {
+	routes atomic.Pointer[routes]
+
+	// mu guards counters and serializes reloads.
+	mu sync.Mutex
+
+	// counters[name] are the counters of the backend. Counters are kept across
+	// reloads as long as the backend name does not change.
+	counters map[string]*counters
+
+	// content of the config file last read by WatchConfig, whether it was
+	// loaded or rejected, so that an invalid file is reported once rather than
+	// on every tick until it changes.
+	lastConfig []byte
+}
+
+// routes is the routing state of one config. It is immutable and replaced as a
+// whole on reload.
+type routes struct {
+	version    int
+	hashHeader string
+	table      *maglev_hash.TypedMaglev[*backend]
+}
+
+type backend struct {
+	BackendConfig
+
+	proxy    *httputil.ReverseProxy
+	counters *counters
+}
+
+type counters struct {
+	requests atomic.Int64
+	errors   atomic.Int64
+}
+
+// BackendStats is the counters of a backend served by ServeStats.
+type BackendStats struct {
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Weight   int    `json:"weight"`
+	Requests int64  `json:"requests"`
+	Errors   int64  `json:"errors"`
+}
+
+// Stats is the response of ServeStats.
+type Stats struct {
+	// Version of the config, starting from 1 and increased by 1 on each reload.
+	Version  int            `json:"version"`
+	Backends []BackendStats `json:"backends"`
+}
+
+// NewLoadBalancer creates a LoadBalancer from the config.
+func NewLoadBalancer(cfg Config) (*LoadBalancer, error) {
+	lb := &LoadBalancer{
+		counters: make(map[string]*counters),
+	}
+	if err := lb.Reload(cfg); err != nil {
+		return nil, err
+	}
+	return lb, nil
+}
+
+// Reload replaces backends with the ones in the config. Requests in flight
+// finish on their backends. If the config is invalid, the current backends are
+// kept and error is returned.
+func (lb *LoadBalancer) Reload(cfg Config) error {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	if cfg.SlotCnt == 0 {
+		cfg.SlotCnt = maglev_hash.DefaultSlotCnt
+	}
+	backends := make([]*backend, 0, len(cfg.Backends))
+	for _, bc := range cfg.Backends {
+		if bc.Weight == 0 {
+			bc.Weight = 1
+		}
+		target, err := url.Parse(bc.URL)
+		if err != nil {
+			return fmt.Errorf("backend %s: %w", bc.Name, err)
+		}
+		if target.Scheme == "" || target.Host == "" {
+			return fmt.Errorf("backend %s: url must be absolute, %s", bc.Name, bc.URL)
+		}
+		c, ok := lb.counters[bc.Name]
+		if !ok {
+			c = &counters{}
+		}
+		b := &backend{
+			BackendConfig: bc,
+			proxy:         httputil.NewSingleHostReverseProxy(target),
+			counters:      c,
+		}
+		b.proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+			c.errors.Add(1)
+			log.Printf("backend %s: %v", bc.Name, err)
+			w.WriteHeader(http.StatusBadGateway)
+		}
+		backends = append(backends, b)
+	}
+	table, err := maglev_hash.NewTypedMaglev(cfg.SlotCnt, backends,
+		func(b *backend) string { return b.Name },
+		func(b *backend) int { return b.Weight },
+		crc32.ChecksumIEEE)
+	if err != nil {
+		return err
+	}
+
+	lb.counters = make(map[string]*counters, len(backends))
+	for _, b := range backends {
+		lb.counters[b.Name] = b.counters
+	}
+	version := 1
+	if current := lb.routes.Load(); current != nil {
+		version = current.version + 1
+	}
+	lb.routes.Store(&routes{
+		version:    version,
+		hashHeader: cfg.HashHeader,
+		table:      table,
+	})
+	return nil
+}
+
+// WatchConfig reloads the config file every interval if its content changes,
+// until ctx is done. Errors are logged and the current backends are kept.
+func (lb *LoadBalancer) WatchConfig(ctx context.Context, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := lb.reloadFile(path); err != nil {
+			log.Printf("failed to reload config %s: %v", path, err)
+		}
+	}
+}
+
+// reloadFile reloads the config file if its content differs from the last one
+// read, including the last one rejected.
+func (lb *LoadBalancer) reloadFile(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	lb.mu.Lock()
+	changed := !bytes.Equal(b, lb.lastConfig)
+	lb.lastConfig = b
+	lb.mu.Unlock()
+	if !changed {
+		return nil
+	}
+	var cfg Config
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return lb.Reload(cfg)
+}
+
+// ServeHTTP proxies the request to its backend.
+func (lb *LoadBalancer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt := lb.routes.Load()
+	b := rt.table.Node([]byte(requestKey(r, rt.hashHeader)))
+	b.counters.requests.Add(1)
+	b.proxy.ServeHTTP(w, r)
+}
+
+// ServeStats serves counters of the current backends as JSON.
+func (lb *LoadBalancer) ServeStats(w http.ResponseWriter, _ *http.Request) {
+	rt := lb.routes.Load()
+	stats := Stats{Version: rt.version}
+	for _, b := range rt.table.Nodes() {
+		stats.Backends = append(stats.Backends, BackendStats{
+			Name:     b.Name,
+			URL:      b.URL,
+			Weight:   b.Weight,
+			Requests: b.counters.requests.Load(),
+			Errors:   b.counters.errors.Load(),
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(stats)
+}
+
+// requestKey returns the key to hash the request by: the value of the header,
+// or the client IP if the header is empty or missing.
+func requestKey(r *http.Request, header string) string {
+	if header != "" {
+		if v := r.Header.Get(header); v != "" {
+			return v
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// newLoadBalancerFromFile creates a LoadBalancer from the config file.
+func newLoadBalancerFromFile(path string) (*LoadBalancer, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	lb, err := NewLoadBalancer(cfg)
+	if err != nil {
+		return nil, err
+	}
+	lb.lastConfig = b
+	return lb, nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newBackends starts n backends. Each responds with its name.
+func newBackends(t *testing.T, n int) []BackendConfig {
+	var backends []BackendConfig
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("B%d", i)
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, name)
+		}))
+		t.Cleanup(s.Close)
+		backends = append(backends, BackendConfig{Name: name, URL: s.URL})
+	}
+	return backends
+}
+
+// get sends a request with the header X-User-ID: user and returns the response body.
+func get(t *testing.T, url, user string) string {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	assert.NoError(t, err)
+	req.Header.Set("X-User-ID", user)
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	return string(b)
+}
+
+func getStats(t *testing.T, lb *LoadBalancer) Stats {
+	w := httptest.NewRecorder()
+	lb.ServeStats(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	var stats Stats
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	return stats
+}
+
+func TestLoadBalancer(t *testing.T) {
+	backends := newBackends(t, 3)
+	lb, err := NewLoadBalancer(Config{HashHeader: "X-User-ID", Backends: backends})
+	assert.NoError(t, err)
+	s := httptest.NewServer(lb)
+	defer s.Close()
+
+	assignment := make(map[string]string)
+	for i := 0; i < 100; i++ {
+		user := fmt.Sprintf("user-%d", i)
+		assignment[user] = get(t, s.URL, user)
+		assert.Equal(t, assignment[user], get(t, s.URL, user))
+	}
+
+	stats := getStats(t, lb)
+	assert.Equal(t, 1, stats.Version)
+	assert.Len(t, stats.Backends, 3)
+	var total int64
+	for _, b := range stats.Backends {
+		assert.Greater(t, b.Requests, int64(0))
+		assert.Equal(t, int64(0), b.Errors)
+		total += b.Requests
+	}
+	assert.Equal(t, int64(200), total)
+
+	// Removing B2 moves users of B2 and few others.
+	assert.NoError(t, lb.Reload(Config{HashHeader: "X-User-ID", Backends: backends[:2]}))
+	moved := 0
+	for user, node := range assignment {
+		got := get(t, s.URL, user)
+		assert.NotEqual(t, "B2", got)
+		if node != "B2" && node != got {
+			moved++
+		}
+	}
+	assert.LessOrEqual(t, moved, 5)
+	stats = getStats(t, lb)
+	assert.Equal(t, 2, stats.Version)
+	assert.Len(t, stats.Backends, 2)
+
+	// An invalid config keeps the current backends.
+	assert.Error(t, lb.Reload(Config{SlotCnt: 13}))
+	assert.Error(t, lb.Reload(Config{SlotCnt: 13, Backends: []BackendConfig{{Name: "B0", URL: "/path"}}}))
+	assert.Equal(t, 2, getStats(t, lb).Version)
+}
+
+func TestLoadBalancer_ClientIP(t *testing.T) {
+	backends := newBackends(t, 3)
+	lb, err := NewLoadBalancer(Config{SlotCnt: 13, Backends: backends})
+	assert.NoError(t, err)
+
+	// Requests from the same client IP go to the same backend regardless of port.
+	var got []string
+	for port := 1000; port < 1010; port++ {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = fmt.Sprintf("10.0.0.1:%d", port)
+		w := httptest.NewRecorder()
+		lb.ServeHTTP(w, r)
+		got = append(got, w.Body.String())
+	}
+	for _, name := range got {
+		assert.Equal(t, got[0], name)
+	}
+}
+
+func TestLoadBalancer_BackendDown(t *testing.T) {
+	s := httptest.NewServer(http.NotFoundHandler())
+	url := s.URL
+	s.Close()
+	lb, err := NewLoadBalancer(Config{SlotCnt: 13, Backends: []BackendConfig{{Name: "B0", URL: url}}})
+	assert.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	lb.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	stats := getStats(t, lb)
+	assert.Equal(t, int64(1), stats.Backends[0].Requests)
+	assert.Equal(t, int64(1), stats.Backends[0].Errors)
+}
+
+func TestLoadBalancer_ReloadFile(t *testing.T) {
+	backends := newBackends(t, 2)
+	path := filepath.Join(t.TempDir(), "lb.json")
+	writeConfig := func(cfg Config) {
+		b, err := json.Marshal(cfg)
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(path, b, 0o644))
+	}
+
+	writeConfig(Config{SlotCnt: 13, Backends: backends[:1]})
+	lb, err := newLoadBalancerFromFile(path)
+	assert.NoError(t, err)
+
+	// Unchanged file is not reloaded.
+	assert.NoError(t, lb.reloadFile(path))
+	assert.Equal(t, 1, getStats(t, lb).Version)
+
+	writeConfig(Config{SlotCnt: 13, Backends: backends})
+	assert.NoError(t, lb.reloadFile(path))
+	stats := getStats(t, lb)
+	assert.Equal(t, 2, stats.Version)
+	assert.Len(t, stats.Backends, 2)
+
+	// Invalid file keeps the current backends, and is reported only once.
+	assert.NoError(t, os.WriteFile(path, []byte("{"), 0o644))
+	assert.Error(t, lb.reloadFile(path))
+	assert.NoError(t, lb.reloadFile(path))
+	assert.Equal(t, 2, getStats(t, lb).Version)
+
+	// So is a well-formed config that fails validation.
+	writeConfig(Config{SlotCnt: 10, Backends: backends})
+	assert.Error(t, lb.reloadFile(path))
+	assert.NoError(t, lb.reloadFile(path))
+	assert.Equal(t, 2, getStats(t, lb).Version)
+
+	// Fixing the file reloads it.
+	writeConfig(Config{SlotCnt: 13, Backends: backends[1:]})
+	assert.NoError(t, lb.reloadFile(path))
+	stats = getStats(t, lb)
+	assert.Equal(t, 3, stats.Version)
+	assert.Len(t, stats.Backends, 1)
+}
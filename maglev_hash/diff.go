@@ -0,0 +1,57 @@
+package maglev_hash
+
+import "fmt"
+
+// SlotMove is a slot that is reassigned from one node to another.
+type SlotMove struct {
+	Slot int
+	From string
+	To   string
+}
+
+// TableDiff is the difference between two Maglev hash tables of the same number
+// of slots.
+type TableDiff struct {
+	// Number of slots of both tables.
+	SlotCnt int
+
+	// Slots that are reassigned, in the ascending order of slot.
+	Moves []SlotMove
+
+	// Gained[node] is the number of slots the node gains.
+	Gained map[string]int
+
+	// Lost[node] is the number of slots the node loses.
+	Lost map[string]int
+}
+
+// Diff returns the slots that are reassigned when switching from the before
+// table to the after table. Keys in moved slots are routed to different nodes
+// after the switch, so callers can pre-warm caches on the gaining nodes and
+// drain connections on the losing nodes. It returns error if the two tables
+// have different number of slots.
+func Diff(before, after *MaglevHash) (*TableDiff, error) {
+	if before.slotCnt != after.slotCnt {
+		return nil, fmt.Errorf("number of slots are different, %d != %d", before.slotCnt, after.slotCnt)
+	}
+	d := &TableDiff{
+		SlotCnt: before.slotCnt,
+		Gained:  make(map[string]int),
+		Lost:    make(map[string]int),
+	}
+	for i := 0; i < before.slotCnt; i++ {
+		from, to := before.nodes[before.lookup.at(i)], after.nodes[after.lookup.at(i)]
+		if from == to {
+			continue
+		}
+		d.Moves = append(d.Moves, SlotMove{Slot: i, From: from, To: to})
+		d.Gained[to]++
+		d.Lost[from]++
+	}
+	return d, nil
+}
+
+// MoveRatio returns the ratio of reassigned slots to all slots.
+func (d *TableDiff) MoveRatio() float64 {
+	return float64(len(d.Moves)) / float64(d.SlotCnt)
+}
@@ -0,0 +1,50 @@
+package maglev_hash_test
+
+import (
+	"fmt"
+	"hash/crc32"
+	"testing"
+
+	"github.com/pengubco/algorithms/maglev_hash"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	slotCnt := 10_007
+	nodes := lo.Times(10, func(i int) string {
+		return fmt.Sprintf("B%d", i)
+	})
+	before, err := maglev_hash.NewMaglevWithTableSize(slotCnt, nodes, crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+
+	d, err := maglev_hash.Diff(before, before)
+	assert.NoError(t, err)
+	assert.Empty(t, d.Moves)
+	assert.Zero(t, d.MoveRatio())
+
+	after, err := before.RemoveNode("B5")
+	assert.NoError(t, err)
+	d, err = maglev_hash.Diff(before, after)
+	assert.NoError(t, err)
+
+	// all slots of B5 are moved to other nodes, along with a few slots of other nodes.
+	assert.Equal(t, len(before.SlotsOf("B5")), d.Lost["B5"])
+	assert.NotContains(t, d.Gained, "B5")
+	assert.Equal(t, len(d.Moves), lo.Sum(lo.Values(d.Gained)))
+	assert.Equal(t, len(d.Moves), lo.Sum(lo.Values(d.Lost)))
+	assert.Less(t, len(d.Moves), 2*slotCnt/10)
+	assert.Equal(t, float64(len(d.Moves))/float64(slotCnt), d.MoveRatio())
+	for i, move := range d.Moves {
+		if i > 0 {
+			assert.Less(t, d.Moves[i-1].Slot, move.Slot)
+		}
+		assert.NotEqual(t, move.From, move.To)
+		assert.NotEqual(t, "B5", move.To)
+	}
+
+	other, err := maglev_hash.NewMaglevWithTableSize(7, nodes[:2], crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+	_, err = maglev_hash.Diff(before, other)
+	assert.Error(t, err)
+}
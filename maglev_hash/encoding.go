@@ -0,0 +1,255 @@
+package maglev_hash
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/pengubco/algorithms/prime"
+)
+
+// The binary form of MaglevHash, all integers in big endian.
+//
+//	magic "MGLV" | format version (1 byte) | slotCnt (uint32) | nodeCnt (uint32)
+//	nodeCnt * (len(node) (uvarint) | node | weight (uvarint))
+//	slotCnt * lookup (uint32)
+//	checksum (SHA-256 of all preceding bytes)
+//
+// Nodes are sorted, so the same table always encodes to the same bytes.
+const (
+	binaryMagic         = "MGLV"
+	binaryFormatVersion = 1
+)
+
+// ErrChecksumMismatch is returned when decoding a table whose checksum does not match its content.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// maglevJSON is the JSON form of MaglevHash. Checksum is the hex encoded checksum
+// of the binary form.
+type maglevJSON struct {
+	SlotCnt  int      `json:"slotCnt"`
+	Nodes    []string `json:"nodes"`
+	Weights  []int    `json:"weights"`
+	Lookup   []int    `json:"lookup"`
+	Checksum string   `json:"checksum"`
+}
+
+// LoadMaglev creates a Maglev hash from the binary form produced by
+// MarshalBinary, using the given key hash function. The lookup table is taken
+// as is, instead of being rebuilt from nodes. Options are not encoded, and the
+// given options are used by AddNode and RemoveNode on the loaded table.
+func LoadMaglev(data []byte, keyHashFn KeyHashFnType, opts ...Options) (*MaglevHash, error) {
+	m := &MaglevHash{keyHashFn: keyHashFn, permutationHashFn: optionsOf(opts).PermutationHashFn}
+	if err := m.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Checksum returns the SHA-256 of the binary form of the table. Two tables
+// have the same checksum iff they have the same slots, nodes, weights and lookup.
+func (m *MaglevHash) Checksum() [sha256.Size]byte {
+	return sha256.Sum256(m.appendPayload(nil))
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. The key hash function is
+// not encoded.
+func (m *MaglevHash) MarshalBinary() ([]byte, error) {
+	payload := m.appendPayload(nil)
+	checksum := sha256.Sum256(payload)
+	return append(payload, checksum[:]...), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. It returns error if the
+// data is corrupted or does not describe a valid table. The key hash function
+// and options of m are kept, CRC32 is used if m does not have a key hash function.
+func (m *MaglevHash) UnmarshalBinary(data []byte) error {
+	if len(data) < len(binaryMagic)+1+sha256.Size {
+		return errors.New("data too short")
+	}
+	payload, checksum := data[:len(data)-sha256.Size], data[len(data)-sha256.Size:]
+	if sum := sha256.Sum256(payload); !bytes.Equal(sum[:], checksum) {
+		return ErrChecksumMismatch
+	}
+	r := bytes.NewReader(payload)
+	header := make([]byte, len(binaryMagic)+1)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return err
+	}
+	if string(header[:len(binaryMagic)]) != binaryMagic {
+		return errors.New("not a Maglev hash table")
+	}
+	if header[len(binaryMagic)] != binaryFormatVersion {
+		return fmt.Errorf("unsupported format version, %d", header[len(binaryMagic)])
+	}
+	var slotCnt, nodeCnt uint32
+	if err := binary.Read(r, binary.BigEndian, &slotCnt); err != nil {
+		return err
+	}
+	if err := binary.Read(r, binary.BigEndian, &nodeCnt); err != nil {
+		return err
+	}
+	if int64(nodeCnt) > int64(r.Len()) || int64(slotCnt)*4 > int64(r.Len()) {
+		return errors.New("data too short")
+	}
+	nodes := make([]string, nodeCnt)
+	weights := make([]int, nodeCnt)
+	for i := range nodes {
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return err
+		}
+		if n > uint64(r.Len()) {
+			return errors.New("data too short")
+		}
+		b := make([]byte, n)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return err
+		}
+		nodes[i] = string(b)
+		w, err := binary.ReadUvarint(r)
+		if err != nil {
+			return err
+		}
+		weights[i] = int(w)
+	}
+	lookup := make([]uint32, slotCnt)
+	if err := binary.Read(r, binary.BigEndian, lookup); err != nil {
+		return err
+	}
+	if r.Len() != 0 {
+		return errors.New("unexpected trailing data")
+	}
+	loaded, err := decodeMaglev(int(slotCnt), nodes, weights, lookup, m.keyHashFn, m.options())
+	if err != nil {
+		return err
+	}
+	*m = *loaded
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. The key hash function is not encoded.
+func (m *MaglevHash) MarshalJSON() ([]byte, error) {
+	checksum := m.Checksum()
+	lookup := make([]int, m.slotCnt)
+	for i := range lookup {
+		lookup[i] = m.lookup.at(i)
+	}
+	return json.Marshal(maglevJSON{
+		SlotCnt:  m.slotCnt,
+		Nodes:    m.nodes,
+		Weights:  m.nodeWeights(),
+		Lookup:   lookup,
+		Checksum: hex.EncodeToString(checksum[:]),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It returns error if the checksum
+// does not match or the data does not describe a valid table. The key hash
+// function and options of m are kept, CRC32 is used if m does not have a key
+// hash function.
+func (m *MaglevHash) UnmarshalJSON(data []byte) error {
+	var v maglevJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.SlotCnt < 0 || v.SlotCnt != len(v.Lookup) {
+		return fmt.Errorf("size of lookup is different from number of slots, %d != %d", len(v.Lookup), v.SlotCnt)
+	}
+	lookup := make([]uint32, len(v.Lookup))
+	for i, j := range v.Lookup {
+		if j < 0 || j >= len(v.Nodes) {
+			return fmt.Errorf("slot %d is assigned to unknown node %d", i, j)
+		}
+		lookup[i] = uint32(j)
+	}
+	loaded, err := decodeMaglev(v.SlotCnt, v.Nodes, v.Weights, lookup, m.keyHashFn, m.options())
+	if err != nil {
+		return err
+	}
+	checksum := loaded.Checksum()
+	if hex.EncodeToString(checksum[:]) != v.Checksum {
+		return ErrChecksumMismatch
+	}
+	*m = *loaded
+	return nil
+}
+
+// appendPayload appends the binary form of the table, without checksum, to b.
+func (m *MaglevHash) appendPayload(b []byte) []byte {
+	b = append(b, binaryMagic...)
+	b = append(b, binaryFormatVersion)
+	b = binary.BigEndian.AppendUint32(b, uint32(m.slotCnt))
+	b = binary.BigEndian.AppendUint32(b, uint32(m.nodeCnt))
+	weights := m.nodeWeights()
+	for i, node := range m.nodes {
+		b = binary.AppendUvarint(b, uint64(len(node)))
+		b = append(b, node...)
+		b = binary.AppendUvarint(b, uint64(weights[i]))
+	}
+	for i := 0; i < m.slotCnt; i++ {
+		b = binary.BigEndian.AppendUint32(b, uint32(m.lookup.at(i)))
+	}
+	return b
+}
+
+// decodeMaglev validates a decoded table and creates a Maglev hash from it.
+// CRC32 is used if keyHashFn is nil.
+func decodeMaglev(slotCnt int, nodes []string, weights []int, lookup []uint32, keyHashFn KeyHashFnType, opts Options) (*MaglevHash, error) {
+	if !prime.IsPrime(slotCnt) {
+		return nil, fmt.Errorf("number of slots must be a prime number, %d", slotCnt)
+	}
+	if len(nodes) == 0 || len(nodes) > slotCnt {
+		return nil, fmt.Errorf("more nodes than slots, %d > %d", len(nodes), slotCnt)
+	}
+	for i := 1; i < len(nodes); i++ {
+		if nodes[i-1] >= nodes[i] {
+			return nil, errors.New("nodes must be sorted and deduplicated")
+		}
+	}
+	if len(weights) != len(nodes) {
+		return nil, fmt.Errorf("number of weights is different from number of nodes, %d != %d", len(weights), len(nodes))
+	}
+	allOne := true
+	for i, w := range weights {
+		if err := validateWeight(nodes[i], w); err != nil {
+			return nil, err
+		}
+		allOne = allOne && w == 1
+	}
+	if allOne {
+		weights = nil
+	}
+	m := &MaglevHash{
+		slotCnt:   slotCnt,
+		nodeCnt:   len(nodes),
+		nodes:     nodes,
+		weights:   weights,
+		lookup:    newSlotTable(slotCnt, len(nodes)),
+		keyHashFn: keyHashFn,
+
+		permutationHashFn: opts.PermutationHashFn,
+	}
+	for i, j := range lookup {
+		if int(j) >= len(nodes) {
+			return nil, fmt.Errorf("slot %d is assigned to unknown node %d", i, j)
+		}
+		m.lookup.set(i, int(j))
+	}
+	m.ownerCnt = m.countOwners()
+	if m.keyHashFn == nil {
+		m.keyHashFn = crc32.ChecksumIEEE
+	}
+	// compute permutations now so that the table is never mutated after creation.
+	var err error
+	if m.perms, err = m.buildPermutations(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
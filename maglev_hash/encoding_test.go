@@ -0,0 +1,112 @@
+package maglev_hash_test
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"testing"
+
+	"github.com/pengubco/algorithms/maglev_hash"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarshalBinary(t *testing.T) {
+	nodes := lo.Times(10, func(i int) string {
+		return fmt.Sprintf("B%d", i)
+	})
+	mh, err := maglev_hash.NewMaglevWithTableSize(1009, nodes, crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+
+	data, err := mh.MarshalBinary()
+	assert.NoError(t, err)
+	loaded, err := maglev_hash.LoadMaglev(data, crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+	assert.NoError(t, verifySameAssignment(mh, loaded, 100_000))
+	assert.Equal(t, mh.Checksum(), loaded.Checksum())
+
+	// the same table always encodes to the same bytes.
+	data2, err := loaded.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, data, data2)
+
+	// corrupted data is rejected.
+	for _, i := range []int{0, 10, len(data) / 2, len(data) - 1} {
+		corrupted := append([]byte{}, data...)
+		corrupted[i] ^= 0xff
+		var m maglev_hash.MaglevHash
+		assert.Error(t, m.UnmarshalBinary(corrupted))
+	}
+	var m maglev_hash.MaglevHash
+	assert.Error(t, m.UnmarshalBinary(data[:len(data)-1]))
+	assert.Error(t, m.UnmarshalBinary(nil))
+
+	// a loaded table supports membership changes.
+	added, err := loaded.AddNode("B10")
+	assert.NoError(t, err)
+	expected, err := mh.AddNode("B10")
+	assert.NoError(t, err)
+	assert.Equal(t, expected.Checksum(), added.Checksum())
+}
+
+func TestMarshalJSON(t *testing.T) {
+	mh, err := maglev_hash.NewWeightedMaglevWithTableSize(1009, map[string]int{"B0": 1, "B1": 2, "B2": 3}, crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+
+	data, err := json.Marshal(mh)
+	assert.NoError(t, err)
+	var loaded maglev_hash.MaglevHash
+	assert.NoError(t, json.Unmarshal(data, &loaded))
+	assert.NoError(t, verifySameAssignment(mh, &loaded, 100_000))
+	assert.Equal(t, mh.Checksum(), loaded.Checksum())
+
+	// the checksum in JSON is the checksum of the binary form.
+	var v map[string]any
+	assert.NoError(t, json.Unmarshal(data, &v))
+	checksum := mh.Checksum()
+	assert.Equal(t, fmt.Sprintf("%x", checksum[:]), v["checksum"])
+
+	// tampered lookup is rejected.
+	lookup := v["lookup"].([]any)
+	lookup[0] = (lookup[0].(float64) + 1)
+	if lookup[0].(float64) > 2 {
+		lookup[0] = float64(0)
+	}
+	tampered, err := json.Marshal(v)
+	assert.NoError(t, err)
+	err = json.Unmarshal(tampered, &loaded)
+	assert.ErrorIs(t, err, maglev_hash.ErrChecksumMismatch)
+
+	invalid := strings.Replace(string(data), `"slotCnt":1009`, `"slotCnt":1000`, 1)
+	assert.Error(t, json.Unmarshal([]byte(invalid), &loaded))
+}
+
+func TestUnmarshal_WeightTooLarge(t *testing.T) {
+	// a well-formed table of one node "B0" of weight 2^40 and 7 slots.
+	payload := []byte("MGLV\x01")
+	payload = binary.BigEndian.AppendUint32(payload, 7)
+	payload = binary.BigEndian.AppendUint32(payload, 1)
+	payload = binary.AppendUvarint(payload, 2)
+	payload = append(payload, "B0"...)
+	payload = binary.AppendUvarint(payload, 1<<40)
+	payload = append(payload, make([]byte, 7*4)...)
+	checksum := sha256.Sum256(payload)
+	var m maglev_hash.MaglevHash
+	err := m.UnmarshalBinary(append(payload, checksum[:]...))
+	assert.ErrorContains(t, err, "weight of node must be at most")
+
+	mh, err := maglev_hash.NewWeightedMaglevWithTableSize(7, map[string]int{"B0": 1, "B1": 2}, crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+	data, err := json.Marshal(mh)
+	assert.NoError(t, err)
+	var v map[string]any
+	assert.NoError(t, json.Unmarshal(data, &v))
+	v["weights"] = []int{1, 1 << 40}
+	data, err = json.Marshal(v)
+	assert.NoError(t, err)
+	err = json.Unmarshal(data, &m)
+	assert.ErrorContains(t, err, "weight of node must be at most")
+}
@@ -0,0 +1,129 @@
+package maglev_hash
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// FlowTable is the connection tracking table from the Maglev paper. It remembers
+// the node of each active flow, so that existing flows stay on their node when
+// the Maglev table changes, and only new flows are routed by the new table.
+// A flow is identified by a key, e.g. the 5-tuple of a connection. It is
+// forgotten after being idle for idleTimeout, or when the table is full and it
+// is the least recently used flow. It is safe for concurrent use.
+//
+// Example
+// ft, _ := NewFlowTable(NewAtomic(mh), 100_000, time.Minute)
+// node := ft.Node([]byte("10.0.0.1:5000->10.0.0.2:80/tcp"))
+type FlowTable struct {
+	table *Atomic
+
+	// max number of flows.
+	capacity int
+
+	idleTimeout time.Duration
+
+	// now returns the current time. It is replaced in tests.
+	now func() time.Time
+
+	mu sync.Mutex
+
+	// flows[key] is the element of the flow in lru.
+	flows map[string]*list.Element
+
+	// Flows ordered by the last access time, the most recent first.
+	lru *list.List
+}
+
+// flow is an entry of FlowTable.
+type flow struct {
+	key        string
+	node       string
+	lastAccess time.Time
+}
+
+// NewFlowTable creates a FlowTable that routes new flows with the current
+// Maglev table of the given Atomic. capacity and idleTimeout must be positive.
+func NewFlowTable(table *Atomic, capacity int, idleTimeout time.Duration) (*FlowTable, error) {
+	if capacity <= 0 {
+		return nil, fmt.Errorf("capacity must be positive, %d", capacity)
+	}
+	if idleTimeout <= 0 {
+		return nil, fmt.Errorf("idle timeout must be positive, %v", idleTimeout)
+	}
+	return &FlowTable{
+		table:       table,
+		capacity:    capacity,
+		idleTimeout: idleTimeout,
+		now:         time.Now,
+		flows:       make(map[string]*list.Element),
+		lru:         list.New(),
+	}, nil
+}
+
+// Node returns the node of the flow. An active flow keeps the node it was
+// assigned to. A new flow, or a flow idle for longer than idleTimeout, is
+// assigned by the current Maglev table and remembered.
+func (f *FlowTable) Node(key []byte) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := f.now()
+	if e, ok := f.flows[string(key)]; ok {
+		fl := e.Value.(*flow)
+		if now.Sub(fl.lastAccess) <= f.idleTimeout {
+			fl.lastAccess = now
+			f.lru.MoveToFront(e)
+			return fl.node
+		}
+		f.remove(e)
+	}
+	fl := &flow{
+		key:        string(key),
+		node:       f.table.Node(key),
+		lastAccess: now,
+	}
+	f.flows[fl.key] = f.lru.PushFront(fl)
+	if f.lru.Len() > f.capacity {
+		f.remove(f.lru.Back())
+	}
+	return fl.node
+}
+
+// Forget removes the flow, e.g. when the connection is closed.
+func (f *FlowTable) Forget(key []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if e, ok := f.flows[string(key)]; ok {
+		f.remove(e)
+	}
+}
+
+// Expire removes flows idle for longer than idleTimeout and returns the number
+// of removed flows. Idle flows are also removed lazily by Node, so calling Expire
+// periodically only frees memory earlier.
+func (f *FlowTable) Expire() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := f.now()
+	cnt := 0
+	for e := f.lru.Back(); e != nil && now.Sub(e.Value.(*flow).lastAccess) > f.idleTimeout; e = f.lru.Back() {
+		f.remove(e)
+		cnt++
+	}
+	return cnt
+}
+
+// Len returns the number of flows in the table, including idle ones not yet removed.
+func (f *FlowTable) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.lru.Len()
+}
+
+// remove removes the flow of the element. Caller must hold mu.
+func (f *FlowTable) remove(e *list.Element) {
+	f.lru.Remove(e)
+	delete(f.flows, e.Value.(*flow).key)
+}
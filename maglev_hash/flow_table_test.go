@@ -0,0 +1,84 @@
+package maglev_hash
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlowTable(t *testing.T) {
+	nodes := lo.Times(10, func(i int) string {
+		return fmt.Sprintf("B%d", i)
+	})
+	mh, err := NewMaglevWithTableSize(1009, nodes, crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+	table := NewAtomic(mh)
+
+	_, err = NewFlowTable(table, 0, time.Minute)
+	assert.Error(t, err)
+	_, err = NewFlowTable(table, 10, 0)
+	assert.Error(t, err)
+
+	ft, err := NewFlowTable(table, 100, time.Minute)
+	assert.NoError(t, err)
+	now := time.Now()
+	ft.now = func() time.Time { return now }
+
+	keys := lo.Times(50, func(i int) []byte { return []byte(strconv.Itoa(i)) })
+	before := lo.Map(keys, func(key []byte, _ int) string { return ft.Node(key) })
+	assert.Equal(t, 50, ft.Len())
+
+	// remove the node of key-0. Existing flows keep their nodes, new flows use the new table.
+	_, err = table.Update(func(mh *MaglevHash) (*MaglevHash, error) {
+		return mh.RemoveNode(before[0])
+	})
+	assert.NoError(t, err)
+	now = now.Add(30 * time.Second)
+	for i, key := range keys {
+		assert.Equal(t, before[i], ft.Node(key))
+	}
+	newKey := []byte("new flow")
+	assert.Equal(t, table.Node(newKey), ft.Node(newKey))
+
+	// a forgotten flow is routed by the new table.
+	ft.Forget(keys[0])
+	assert.Equal(t, table.Node(keys[0]), ft.Node(keys[0]))
+	assert.NotEqual(t, before[0], ft.Node(keys[0]))
+
+	// idle flows expire.
+	now = now.Add(50 * time.Second)
+	ft.Node(keys[1])
+	now = now.Add(20 * time.Second)
+	assert.Equal(t, 50, ft.Expire())
+	assert.Equal(t, 1, ft.Len())
+	assert.Equal(t, before[1], ft.Node(keys[1]))
+}
+
+func TestFlowTable_Capacity(t *testing.T) {
+	mh, err := NewMaglevWithTableSize(1009, []string{"B0", "B1"}, crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+	ft, err := NewFlowTable(NewAtomic(mh), 10, time.Minute)
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				key := md5.Sum(binary.BigEndian.AppendUint64(nil, uint64(i*1000+j)))
+				assert.NotEmpty(t, ft.Node(key[:]))
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, 10, ft.Len())
+}
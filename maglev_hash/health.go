@@ -0,0 +1,94 @@
+package maglev_hash
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// HealthAwareMaglev routes keys of a MaglevHash to healthy nodes. Marking a node
+// down takes effect immediately without rebuilding the table: keys of the down
+// node fall through to the next healthy node in the order of NodesN, and keys of
+// healthy nodes stay where they are. Only rebuilding the table without the node
+// redistributes its slots. It is safe for concurrent use and lookups are lock-free.
+//
+// Example
+// h := NewHealthAwareMaglev(mh)
+// h.MarkDown("B1")
+// node, ok := h.Node([]byte("key1"))
+type HealthAwareMaglev struct {
+	mh *MaglevHash
+
+	// down[i] is true iff node[i] is marked down.
+	down []atomic.Bool
+}
+
+// NewHealthAwareMaglev creates a HealthAwareMaglev where all nodes are healthy.
+func NewHealthAwareMaglev(mh *MaglevHash) *HealthAwareMaglev {
+	return &HealthAwareMaglev{
+		mh:   mh,
+		down: make([]atomic.Bool, mh.nodeCnt),
+	}
+}
+
+// WithTable returns a HealthAwareMaglev over the given table, typically a
+// rebuilt one. Nodes that are down in h stay down if they exist in the table.
+func (h *HealthAwareMaglev) WithTable(mh *MaglevHash) *HealthAwareMaglev {
+	result := NewHealthAwareMaglev(mh)
+	for i, node := range mh.nodes {
+		if j, ok := h.mh.nodeIndex(node); ok {
+			result.down[i].Store(h.down[j].Load())
+		}
+	}
+	return result
+}
+
+// Table returns the underlying Maglev hash.
+func (h *HealthAwareMaglev) Table() *MaglevHash {
+	return h.mh
+}
+
+// MarkDown marks the node unhealthy. It returns error if the node does not exist.
+func (h *HealthAwareMaglev) MarkDown(node string) error {
+	return h.mark(node, true)
+}
+
+// MarkUp marks the node healthy. It returns error if the node does not exist.
+func (h *HealthAwareMaglev) MarkUp(node string) error {
+	return h.mark(node, false)
+}
+
+// IsHealthy returns true iff the node exists and is not marked down.
+func (h *HealthAwareMaglev) IsHealthy(node string) bool {
+	i, ok := h.mh.nodeIndex(node)
+	return ok && !h.down[i].Load()
+}
+
+// Node returns the assigned healthy node for the given key. It returns false if
+// no node owning slots is healthy.
+func (h *HealthAwareMaglev) Node(key []byte) (string, bool) {
+	slot := h.mh.Slot(key)
+	if i := h.mh.lookup.at(slot); !h.down[i].Load() {
+		return h.mh.nodes[i], true
+	}
+	result := -1
+	h.mh.walk(slot, func(i int) bool {
+		if h.down[i].Load() {
+			return true
+		}
+		result = i
+		return false
+	})
+	if result < 0 {
+		return "", false
+	}
+	return h.mh.nodes[result], true
+}
+
+func (h *HealthAwareMaglev) mark(node string, down bool) error {
+	i, ok := h.mh.nodeIndex(node)
+	if !ok {
+		return fmt.Errorf("node does not exist, %s", node)
+	}
+	h.down[i].Store(down)
+	return nil
+}
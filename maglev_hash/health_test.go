@@ -0,0 +1,62 @@
+package maglev_hash_test
+
+import (
+	"fmt"
+	"hash/crc32"
+	"strconv"
+	"testing"
+
+	"github.com/pengubco/algorithms/maglev_hash"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthAwareMaglev(t *testing.T) {
+	nodes := lo.Times(10, func(i int) string {
+		return fmt.Sprintf("B%d", i)
+	})
+	mh, err := maglev_hash.NewMaglevWithTableSize(1009, nodes, crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+	h := maglev_hash.NewHealthAwareMaglev(mh)
+	assert.Same(t, mh, h.Table())
+
+	assert.Error(t, h.MarkDown("B10"))
+	assert.Error(t, h.MarkUp("B10"))
+	assert.False(t, h.IsHealthy("B10"))
+
+	assert.NoError(t, h.MarkDown("B3"))
+	assert.False(t, h.IsHealthy("B3"))
+	for i := 0; i < 10_000; i++ {
+		key := []byte(strconv.Itoa(i))
+		node, ok := h.Node(key)
+		assert.True(t, ok)
+		if owner := mh.Node(key); owner != "B3" {
+			// keys of healthy nodes are not moved.
+			assert.Equal(t, owner, node)
+		} else {
+			// keys of the down node fall through to the next preference.
+			assert.Equal(t, mh.NodesN(key, 2)[1], node)
+		}
+	}
+
+	// the down mark is kept on a rebuilt table.
+	rebuilt, err := mh.AddNode("B10")
+	assert.NoError(t, err)
+	h2 := h.WithTable(rebuilt)
+	assert.False(t, h2.IsHealthy("B3"))
+	assert.True(t, h2.IsHealthy("B10"))
+
+	assert.NoError(t, h.MarkUp("B3"))
+	for i := 0; i < 10_000; i++ {
+		key := []byte(strconv.Itoa(i))
+		node, ok := h.Node(key)
+		assert.True(t, ok)
+		assert.Equal(t, mh.Node(key), node)
+	}
+
+	for _, node := range nodes {
+		assert.NoError(t, h.MarkDown(node))
+	}
+	_, ok := h.Node([]byte("key"))
+	assert.False(t, ok)
+}
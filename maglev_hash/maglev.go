@@ -10,9 +10,18 @@
 //     than the node with the least slots.
 //  2. Minimal disruption. On average, add a new node causes reassigns M/N slots.
 //
+// Each node prefers slots in the order of a permutation generated from two
+// hashes of the node, offset and skip. The hash function can be set by Options.
+//
+// Nodes can also be weighted, in which case a node owns slots in proportion to
+// its weight. Changing the weight of one node only moves slots to or from that node.
+//
 // Example
 // mh, _ := NewMaglev([]string{"B0", "B1"})
 // node := mh.Node([]byte("key1"))
+//
+// wmh, _ := NewWeightedMaglevWithTableSize(DefaultSlotCnt, map[string]int{"B0": 1, "B1": 2}, crc32.ChecksumIEEE)
+// node = wmh.Node([]byte("key1"))
 package maglev_hash
 
 import (
@@ -20,25 +29,50 @@ import (
 	"encoding/hex"
 	"fmt"
 	"hash/crc32"
+	"math"
 	"math/big"
+	"slices"
 	"sort"
 
+	"github.com/pengubco/algorithms/consistent_hash"
 	"github.com/pengubco/algorithms/prime"
 	"github.com/samber/lo"
 )
 
 const (
 	// The default number of slots is the smallest prime number larger than 10,000.
-	// It should be OK for less than 100 nodes.
+	// It should be OK for less than 100 nodes. Use NewAutoSizedMaglev for more nodes.
 	DefaultSlotCnt = 10007
+
+	// MaxWeight is the largest weight of a node. Building the lookup table
+	// multiplies a weight by the number of rounds, which is at most the number
+	// of slots. Capping weights at 2^31-1 keeps the product from overflowing for
+	// tables of fewer than 2^32 slots.
+	MaxWeight = math.MaxInt32
 )
 
 // KeyHashFnType
-type KeyHashFnType func([]byte) uint32
+type KeyHashFnType = consistent_hash.KeyHashFnType
+
+var _ consistent_hash.ConsistentHash = (*MaglevHash)(nil)
+
+// PermutationHashFnType generates the preference list of a node over slotCnt
+// slots. The j-th preference of the node is slot (offset + j*skip) % slotCnt.
+// offset must be in [0, slotCnt) and skip must be in [1, slotCnt).
+type PermutationHashFnType func(node string, slotCnt int) (offset, skip int)
+
+// Options are optional settings of a Maglev hash.
+type Options struct {
+	// PermutationHashFn generates preference lists of nodes. The default is
+	// FastPermutationHash. Use MD5PermutationHash to reproduce tables built by
+	// versions before PermutationHashFn was introduced.
+	PermutationHashFn PermutationHashFnType
+}
 
 // MaglevHash assigns fixed number of slots to a collection of nodes.
 // 1. Nodes are identified by string.
 // 2. The default hash function hashing key to slot is CRC32.
+// 3. MaglevHash is immutable. AddNode and RemoveNode return a new MaglevHash.
 type MaglevHash struct {
 	// Number of slots.
 	slotCnt int
@@ -48,10 +82,23 @@ type MaglevHash struct {
 
 	nodes []string
 
-	// The mapping from slot to node. lookup[i]=j: slot[i] is mapped to node[j]
-	lookup []int
+	// weights[i] is the weight of node[i]. nil means all nodes have the same weight.
+	weights []int
+
+	// perms[i] is the preference list of node[i]. It is cached so that adding or
+	// removing a node does not recompute the preference lists of other nodes.
+	perms []permutation
+
+	// The mapping from slot to node. lookup.at(i)=j: slot[i] is mapped to node[j]
+	lookup slotTable
+
+	// Number of nodes owning at least one slot. A node of a tiny weight next to
+	// nodes of huge weights may own no slot.
+	ownerCnt int
 
 	keyHashFn KeyHashFnType
+
+	permutationHashFn PermutationHashFnType
 }
 
 // NewMaglev creates a Maglev hash for the given nodes, using default number of
@@ -62,13 +109,54 @@ func NewMaglev(nodes []string) (*MaglevHash, error) {
 
 // NewMaglevWithTableSize creates a Maglev hash. Nodes are identified by strings.
 // In order to make lookup table stable, the given list of nodes are sorted and
-// deduplicated.
-func NewMaglevWithTableSize(slotCnt int, nodes []string, keyHashFn KeyHashFnType) (*MaglevHash, error) {
+// deduplicated. At most one Options can be given.
+func NewMaglevWithTableSize(slotCnt int, nodes []string, keyHashFn KeyHashFnType, opts ...Options) (*MaglevHash, error) {
+	nodes = lo.Uniq(nodes)
+	sort.Strings(nodes)
+	return newMaglev(slotCnt, nodes, nil, nil, keyHashFn, optionsOf(opts))
+}
+
+// NewWeightedMaglevWithTableSize creates a Maglev hash where each node owns a
+// number of slots proportional to its weight. Weights must be in [1, MaxWeight].
+// For example, given {"B0": 1, "B1": 3}, B1 owns about 3 times as many slots as B0.
+func NewWeightedMaglevWithTableSize(slotCnt int, nodeWeights map[string]int, keyHashFn KeyHashFnType, opts ...Options) (*MaglevHash, error) {
+	nodes := lo.Keys(nodeWeights)
+	sort.Strings(nodes)
+	weights := make([]int, len(nodes))
+	for i, node := range nodes {
+		if err := validateWeight(node, nodeWeights[node]); err != nil {
+			return nil, err
+		}
+		weights[i] = nodeWeights[node]
+	}
+	return newMaglev(slotCnt, nodes, weights, nil, keyHashFn, optionsOf(opts))
+}
+
+// validateWeight returns error if the weight of the node is not in [1, MaxWeight].
+func validateWeight(node string, weight int) error {
+	if weight <= 0 {
+		return fmt.Errorf("weight of node must be positive, %s: %d", node, weight)
+	}
+	if weight > MaxWeight {
+		return fmt.Errorf("weight of node must be at most %d, %s: %d", MaxWeight, node, weight)
+	}
+	return nil
+}
+
+// optionsOf returns the first of the given options, or the default options.
+func optionsOf(opts []Options) Options {
+	if len(opts) == 0 {
+		return Options{}
+	}
+	return opts[0]
+}
+
+// newMaglev creates a Maglev hash from sorted and deduplicated nodes. perms are
+// the cached permutations of nodes, nil means computing them from scratch.
+func newMaglev(slotCnt int, nodes []string, weights []int, perms []permutation, keyHashFn KeyHashFnType, opts Options) (*MaglevHash, error) {
 	if !prime.IsPrime(slotCnt) {
 		return nil, fmt.Errorf("number of slots must be a prime number, %d", slotCnt)
 	}
-	nodes = lo.Uniq(nodes)
-	sort.Strings(nodes)
 	if len(nodes) == 0 || len(nodes) > slotCnt {
 		return nil, fmt.Errorf("more nodes than slots, %d > %d", len(nodes), slotCnt)
 	}
@@ -76,37 +164,236 @@ func NewMaglevWithTableSize(slotCnt int, nodes []string, keyHashFn KeyHashFnType
 		slotCnt:   slotCnt,
 		nodeCnt:   len(nodes),
 		nodes:     nodes,
+		weights:   weights,
+		perms:     perms,
 		keyHashFn: keyHashFn,
+
+		permutationHashFn: opts.PermutationHashFn,
+	}
+	if m.perms == nil {
+		var err error
+		if m.perms, err = m.buildPermutations(); err != nil {
+			return nil, err
+		}
 	}
-	m.lookup = m.buildLookup(m.buildPreferences())
+	m.lookup = m.buildLookup(m.perms)
+	m.ownerCnt = m.countOwners()
 	return m, nil
 }
 
 // Node returns the assigned node for the given key.
 func (m *MaglevHash) Node(key []byte) string {
-	return m.nodes[m.lookup[m.keyHashFn(key)%uint32(m.slotCnt)]]
+	return m.nodes[m.lookup.at(m.Slot(key))]
+}
+
+// Nodes returns the sorted nodes.
+func (m *MaglevHash) Nodes() []string {
+	return slices.Clone(m.nodes)
+}
+
+// NodesN returns n distinct nodes for the given key in the order of preference.
+// The first node is the one returned by Node, the following nodes are owners of
+// the subsequent slots, skipping nodes already returned. The order only depends
+// on the table, so it is the same across processes. Fewer than n nodes are
+// returned if the table does not have n nodes owning slots. It is intended for
+// small n, e.g. picking replicas or a retry target.
+func (m *MaglevHash) NodesN(key []byte, n int) []string {
+	result := make([]string, 0, min(n, m.nodeCnt))
+	if n <= 0 {
+		return result
+	}
+	m.walk(m.Slot(key), func(i int) bool {
+		result = append(result, m.nodes[i])
+		return len(result) < n
+	})
+	return result
+}
+
+// SlotCnt returns the number of slots.
+func (m *MaglevHash) SlotCnt() int {
+	return m.slotCnt
+}
+
+// Slot returns the slot the key is hashed to, in [0, SlotCnt()). Together with
+// NodeOfSlot and SlotsOf, slots can be used as the unit of sharding, e.g.
+// migrating data slot by slot when the owner of a slot changes.
+func (m *MaglevHash) Slot(key []byte) int {
+	return int(m.keyHashFn(key) % uint32(m.slotCnt))
+}
+
+// NodeOfSlot returns the node the slot is assigned to. It panics if slot is not
+// in [0, SlotCnt()).
+func (m *MaglevHash) NodeOfSlot(slot int) string {
+	return m.nodes[m.lookup.at(slot)]
+}
+
+// SlotsOf returns slots assigned to the node in ascending order. It returns nil
+// if the node does not exist.
+func (m *MaglevHash) SlotsOf(node string) []int {
+	i, ok := m.nodeIndex(node)
+	if !ok {
+		return nil
+	}
+	var result []int
+	for slot := 0; slot < m.slotCnt; slot++ {
+		if m.lookup.at(slot) == i {
+			result = append(result, slot)
+		}
+	}
+	return result
 }
 
-// buildLookup calculates the lookup table for slot.
-func (m *MaglevHash) buildLookup(preferenceList [][]int) []int {
-	lookup := make([]int, m.slotCnt)
-	for i := 0; i < m.slotCnt; i++ {
-		lookup[i] = -1
+// SlotOwnership returns slots assigned to each node, in ascending order.
+func (m *MaglevHash) SlotOwnership() map[string][]int {
+	result := make(map[string][]int, m.nodeCnt)
+	for _, node := range m.nodes {
+		result[node] = []int{}
 	}
-	// next[i] indicate the current favorite slot for the i-th node.
+	for slot := 0; slot < m.slotCnt; slot++ {
+		node := m.nodes[m.lookup.at(slot)]
+		result[node] = append(result[node], slot)
+	}
+	return result
+}
+
+// walk calls fn with the distinct nodes owning slot, slot+1, ..., wrapping
+// around at the end of the table, until fn returns false or all nodes owning
+// slots are visited. Nodes owning no slot are never visited, and stopping at
+// ownerCnt instead of nodeCnt keeps walk from scanning the whole table for
+// them. fn is called with the index of node.
+func (m *MaglevHash) walk(slot int, fn func(i int) bool) {
+	visited := make([]int, 0, 8)
+	for k := 0; k < m.slotCnt && len(visited) < m.ownerCnt; k++ {
+		i := m.lookup.at((slot + k) % m.slotCnt)
+		if slices.Contains(visited, i) {
+			continue
+		}
+		visited = append(visited, i)
+		if !fn(i) {
+			return
+		}
+	}
+}
+
+// countOwners returns the number of nodes owning at least one slot.
+func (m *MaglevHash) countOwners() int {
+	owned := make([]bool, m.nodeCnt)
+	cnt := 0
+	for slot := 0; slot < m.slotCnt; slot++ {
+		if i := m.lookup.at(slot); !owned[i] {
+			owned[i] = true
+			cnt++
+		}
+	}
+	return cnt
+}
+
+// AddNode returns a new Maglev hash with the given node added. The new node has
+// weight 1. Permutations of existing nodes are reused, so only the new node's
+// permutation is computed.
+func (m *MaglevHash) AddNode(node string) (*MaglevHash, error) {
+	return m.AddWeightedNode(node, 1)
+}
+
+// AddWeightedNode returns a new Maglev hash with the given node of the given
+// weight added. It returns error if the node already exists or the weight is not
+// in [1, MaxWeight].
+func (m *MaglevHash) AddWeightedNode(node string, weight int) (*MaglevHash, error) {
+	if err := validateWeight(node, weight); err != nil {
+		return nil, err
+	}
+	i := sort.SearchStrings(m.nodes, node)
+	if i < m.nodeCnt && m.nodes[i] == node {
+		return nil, fmt.Errorf("node already exists, %s", node)
+	}
+	p, err := m.newPermutation(node)
+	if err != nil {
+		return nil, err
+	}
+	nodes := insertAt(m.nodes, i, node)
+	perms := insertAt(m.perms, i, p)
+	var weights []int
+	if m.weights != nil || weight != 1 {
+		weights = insertAt(m.nodeWeights(), i, weight)
+	}
+	return newMaglev(m.slotCnt, nodes, weights, perms, m.keyHashFn, m.options())
+}
+
+// RemoveNode returns a new Maglev hash with the given node removed. It returns
+// error if the node does not exist or it is the last node.
+func (m *MaglevHash) RemoveNode(node string) (*MaglevHash, error) {
+	i, ok := m.nodeIndex(node)
+	if !ok {
+		return nil, fmt.Errorf("node does not exist, %s", node)
+	}
+	if m.nodeCnt == 1 {
+		return nil, fmt.Errorf("cannot remove the last node, %s", node)
+	}
+	nodes := removeAt(m.nodes, i)
+	perms := removeAt(m.perms, i)
+	var weights []int
+	if m.weights != nil {
+		weights = removeAt(m.weights, i)
+	}
+	return newMaglev(m.slotCnt, nodes, weights, perms, m.keyHashFn, m.options())
+}
+
+// options returns the options the Maglev hash is created with.
+func (m *MaglevHash) options() Options {
+	return Options{PermutationHashFn: m.permutationHashFn}
+}
+
+// nodeIndex returns the index of the given node in nodes.
+func (m *MaglevHash) nodeIndex(node string) (int, bool) {
+	i := sort.SearchStrings(m.nodes, node)
+	return i, i < m.nodeCnt && m.nodes[i] == node
+}
+
+// nodeWeights returns weights of nodes. nodeWeights()[i] is the weight of node[i].
+func (m *MaglevHash) nodeWeights() []int {
+	if m.weights == nil {
+		return lo.Times(m.nodeCnt, func(int) int { return 1 })
+	}
+	return m.weights
+}
+
+// buildLookup calculates the lookup table for slot from permutations of nodes.
+// Preferences of a node are generated on demand from its permutation, so the
+// memory used besides the lookup table is constant per node.
+// In each round, a node takes its next favorite slot if its weight allows. The
+// node of the max weight takes one slot every round, a node of half the max
+// weight takes one slot every other round, and so on. Without weights, every
+// node takes one slot every round.
+func (m *MaglevHash) buildLookup(perms []permutation) slotTable {
+	lookup := newSlotTable(m.slotCnt, m.nodeCnt)
+	// assigned[i] is true iff slot[i] has been assigned to a node.
+	assigned := make([]bool, m.slotCnt)
+	// next[i] indicate the current favorite slot for the i-th node is its
+	// next[i]-th preference.
 	next := make([]int, m.nodeCnt)
 
+	weights := m.nodeWeights()
+	maxWeight := lo.Max(weights)
+	// threshold[i] is the weight the i-th node must accumulate before taking
+	// its next slot. A node accumulates its weight every round.
+	threshold := lo.Times(m.nodeCnt, func(int) int { return maxWeight })
+
 	// number of slots that have been assigned to nodes.
 	assignedSlotCnt := 0
 
-	for {
+	for round := 1; ; round++ {
 		for i := 0; i < m.nodeCnt; i++ {
-			c := preferenceList[i][next[i]]
-			for lookup[c] >= 0 {
+			if round*weights[i] < threshold[i] {
+				continue
+			}
+			threshold[i] += maxWeight
+			c := perms[i].at(next[i], m.slotCnt)
+			for assigned[c] {
 				next[i]++
-				c = preferenceList[i][next[i]]
+				c = perms[i].at(next[i], m.slotCnt)
 			}
-			lookup[c] = i
+			lookup.set(c, i)
+			assigned[c] = true
 			next[i]++
 			assignedSlotCnt++
 			if assignedSlotCnt == m.slotCnt {
@@ -116,22 +403,91 @@ func (m *MaglevHash) buildLookup(preferenceList [][]int) []int {
 	}
 }
 
-// buildPreferences builds the preference list of slots for each node.
-// Preference list of node. nodePreferences[i][j]=k means that, for node[i],
-// the j-th preference is slot[k].
-// nodePreferences[i] is a permutation of [0, slotCnt)
-func (m *MaglevHash) buildPreferences() [][]int {
-	n := len(m.nodes)
-	nodePreferences := make([][]int, n)
+// buildPermutations computes permutations of all nodes.
+func (m *MaglevHash) buildPermutations() ([]permutation, error) {
+	perms := make([]permutation, len(m.nodes))
 	for i, node := range m.nodes {
-		nodePreferences[i] = make([]int, m.slotCnt)
-		offset := md5StringToModulo(fmt.Sprintf("%s:offset", node), m.slotCnt)
-		skip := md5StringToModulo(fmt.Sprintf("%s:skip", node), m.slotCnt-1) + 1
-		for j := 0; j < m.slotCnt; j++ {
-			nodePreferences[i][j] = (offset + j*skip) % m.slotCnt
+		p, err := m.newPermutation(node)
+		if err != nil {
+			return nil, err
 		}
+		perms[i] = p
 	}
-	return nodePreferences
+	return perms, nil
+}
+
+// newPermutation computes the permutation of the given node.
+func (m *MaglevHash) newPermutation(node string) (permutation, error) {
+	fn := m.permutationHashFn
+	if fn == nil {
+		fn = FastPermutationHash
+	}
+	offset, skip := fn(node, m.slotCnt)
+	if offset < 0 || offset >= m.slotCnt || skip < 1 || skip >= m.slotCnt {
+		return permutation{}, fmt.Errorf("invalid permutation of node %s, offset: %d, skip: %d", node, offset, skip)
+	}
+	return permutation{offset: offset, skip: skip}, nil
+}
+
+// permutation is a node's preference list of slots. The j-th preference is
+// slot (offset + j*skip) % slotCnt. Because slotCnt is a prime, the preference
+// list is a permutation of [0, slotCnt).
+type permutation struct {
+	offset int
+	skip   int
+}
+
+// at returns the j-th preference of the permutation.
+func (p permutation) at(j, slotCnt int) int {
+	return (p.offset + j*p.skip) % slotCnt
+}
+
+// FastPermutationHash is the default permutation hash function. offset and skip
+// come from two seeded 64-bit FNV-1a hashes of the node, each followed by the
+// SplitMix64 finalizer to spread bits. It does not allocate.
+func FastPermutationHash(node string, slotCnt int) (offset, skip int) {
+	offset = int(mix64(fnv1a64(node, offsetSeed)) % uint64(slotCnt))
+	skip = int(mix64(fnv1a64(node, skipSeed))%uint64(slotCnt-1)) + 1
+	return offset, skip
+}
+
+// MD5PermutationHash computes offset and skip from the MD5 of "<node>:offset"
+// and "<node>:skip". It reproduces tables built by versions before
+// PermutationHashFn was introduced. It is slow because it allocates a hex string
+// and big.Int for each hash.
+func MD5PermutationHash(node string, slotCnt int) (offset, skip int) {
+	offset = md5StringToModulo(fmt.Sprintf("%s:offset", node), slotCnt)
+	skip = md5StringToModulo(fmt.Sprintf("%s:skip", node), slotCnt-1) + 1
+	return offset, skip
+}
+
+const (
+	// offsetSeed is the 64-bit FNV offset basis, skipSeed is the basis xor-ed
+	// with the golden ratio so that the two hashes are independent.
+	offsetSeed uint64 = 14695981039346656037
+	skipSeed   uint64 = offsetSeed ^ 0x9e3779b97f4a7c15
+
+	fnvPrime64 uint64 = 1099511628211
+)
+
+// fnv1a64 returns the 64-bit FNV-1a hash of s, starting from the given seed.
+func fnv1a64(s string, seed uint64) uint64 {
+	h := seed
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= fnvPrime64
+	}
+	return h
+}
+
+// mix64 is the finalizer of SplitMix64.
+func mix64(h uint64) uint64 {
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return h
 }
 
 // md5StringToModulo calculate the MD5 of the given string and module the result
@@ -144,3 +500,68 @@ func md5StringToModulo(s string, n int) int {
 
 	return int(big.NewInt(0).Mod(hashInt, moduloNumber).Int64())
 }
+
+// insertAt returns a new slice with v inserted at index i of a.
+func insertAt[T any](a []T, i int, v T) []T {
+	result := make([]T, 0, len(a)+1)
+	result = append(result, a[:i]...)
+	result = append(result, v)
+	return append(result, a[i:]...)
+}
+
+// removeAt returns a new slice with the element at index i of a removed.
+func removeAt[T any](a []T, i int) []T {
+	result := make([]T, 0, len(a)-1)
+	result = append(result, a[:i]...)
+	return append(result, a[i+1:]...)
+}
+
+// slotTable maps slot to the index of node. It stores indexes in the narrowest
+// unsigned integer type that fits the number of nodes. It is a concrete type
+// rather than an interface so that at, which is on the path of every lookup,
+// is inlined instead of dispatched dynamically.
+type slotTable struct {
+	// width is the number of bytes of an index, 1, 2 or 4. Only the slice of
+	// the width is used.
+	width int
+	u8    []uint8
+	u16   []uint16
+	u32   []uint32
+}
+
+// at returns the index of node that slot is mapped to.
+func (t *slotTable) at(slot int) int {
+	switch t.width {
+	case 1:
+		return int(t.u8[slot])
+	case 2:
+		return int(t.u16[slot])
+	default:
+		return int(t.u32[slot])
+	}
+}
+
+// set maps slot to the i-th node.
+func (t *slotTable) set(slot, i int) {
+	switch t.width {
+	case 1:
+		t.u8[slot] = uint8(i)
+	case 2:
+		t.u16[slot] = uint16(i)
+	default:
+		t.u32[slot] = uint32(i)
+	}
+}
+
+// newSlotTable returns a slotTable of slotCnt slots that can store indexes of
+// nodeCnt nodes.
+func newSlotTable(slotCnt, nodeCnt int) slotTable {
+	switch {
+	case nodeCnt <= math.MaxUint8+1:
+		return slotTable{width: 1, u8: make([]uint8, slotCnt)}
+	case nodeCnt <= math.MaxUint16+1:
+		return slotTable{width: 2, u16: make([]uint16, slotCnt)}
+	default:
+		return slotTable{width: 4, u32: make([]uint32, slotCnt)}
+	}
+}
@@ -0,0 +1,240 @@
+package maglev_hash_test
+
+import (
+	"fmt"
+	"hash/crc32"
+	"math"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/pengubco/algorithms/maglev_hash"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+)
+
+// verifyInvariants checks that every slot is assigned to one of the nodes, and
+// the number of slots a node owns is within the bound. Without weights, every
+// node takes one slot per round, so slot counts differ by at most 1. With
+// weights, a node of weight w takes w/maxWeight slots per round, which bounds
+// its slot count by M*w/W ± (1 + N*w/W).
+func verifyInvariants(mh *maglev_hash.MaglevHash, weights map[string]int) error {
+	ownership := mh.SlotOwnership()
+	assigned := 0
+	for _, node := range mh.Nodes() {
+		assigned += len(ownership[node])
+	}
+	if len(ownership) != len(mh.Nodes()) || assigned != mh.SlotCnt() {
+		return fmt.Errorf("%d of %d slots assigned to %d nodes", assigned, mh.SlotCnt(), len(ownership))
+	}
+	for slot := 0; slot < mh.SlotCnt(); slot++ {
+		if _, ok := ownership[mh.NodeOfSlot(slot)]; !ok {
+			return fmt.Errorf("slot %d assigned to unknown node %s", slot, mh.NodeOfSlot(slot))
+		}
+	}
+
+	if weights == nil {
+		counts := lo.MapValues(ownership, func(slots []int, _ string) int { return len(slots) })
+		minCnt, maxCnt := lo.Min(lo.Values(counts)), lo.Max(lo.Values(counts))
+		if maxCnt-minCnt > 1 {
+			return fmt.Errorf("slot count ranges from %d to %d", minCnt, maxCnt)
+		}
+		return nil
+	}
+	totalWeight := lo.Sum(lo.Values(weights))
+	for node, w := range weights {
+		share := float64(w) / float64(totalWeight)
+		expected := float64(mh.SlotCnt()) * share
+		bound := 1 + float64(len(weights))*share
+		if math.Abs(float64(len(ownership[node]))-expected) > bound {
+			return fmt.Errorf("node %s of weight %d owns %d slots, expected %.2f ± %.2f",
+				node, w, len(ownership[node]), expected, bound)
+		}
+	}
+	return nil
+}
+
+// verifySameTable checks that two tables assign every slot to the same node.
+func verifySameTable(mh1, mh2 *maglev_hash.MaglevHash) error {
+	if mh1.SlotCnt() != mh2.SlotCnt() {
+		return fmt.Errorf("slot counts differ, %d != %d", mh1.SlotCnt(), mh2.SlotCnt())
+	}
+	for slot := 0; slot < mh1.SlotCnt(); slot++ {
+		if mh1.NodeOfSlot(slot) != mh2.NodeOfSlot(slot) {
+			return fmt.Errorf("slot %d: %s != %s", slot, mh1.NodeOfSlot(slot), mh2.NodeOfSlot(slot))
+		}
+	}
+	return nil
+}
+
+func TestInvariants(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, slotCnt := range []int{13, 251, 10_007} {
+		for _, nodeCnt := range []int{1, 2, 3, 10, 13, 100} {
+			if nodeCnt > slotCnt {
+				continue
+			}
+			nodes := lo.Times(nodeCnt, func(i int) string { return fmt.Sprintf("B%d", i) })
+			mh, err := maglev_hash.NewMaglevWithTableSize(slotCnt, nodes, crc32.ChecksumIEEE)
+			assert.NoError(t, err)
+			assert.NoError(t, verifyInvariants(mh, nil), "%d nodes, %d slots", nodeCnt, slotCnt)
+
+			weights := lo.SliceToMap(nodes, func(node string) (string, int) { return node, 1 + r.Intn(10) })
+			wmh, err := maglev_hash.NewWeightedMaglevWithTableSize(slotCnt, weights, crc32.ChecksumIEEE)
+			assert.NoError(t, err)
+			assert.NoError(t, verifyInvariants(wmh, weights), "%d nodes, %d slots, weights %v", nodeCnt, slotCnt, weights)
+		}
+	}
+}
+
+func TestDeterminism(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	nodes := lo.Times(20, func(i int) string { return fmt.Sprintf("B%d", i) })
+	mh, err := maglev_hash.NewMaglevWithTableSize(251, nodes, crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+
+	for i := 0; i < 20; i++ {
+		// shuffled with duplicates
+		shuffled := append(lo.Shuffle(append([]string{}, nodes...)), lo.Samples(nodes, r.Intn(len(nodes)))...)
+		lo.Shuffle(shuffled)
+		mh2, err := maglev_hash.NewMaglevWithTableSize(251, shuffled, crc32.ChecksumIEEE)
+		assert.NoError(t, err)
+		assert.NoError(t, verifySameTable(mh, mh2))
+	}
+
+	// Equal weights are the same as no weights.
+	wmh, err := maglev_hash.NewWeightedMaglevWithTableSize(251,
+		lo.SliceToMap(nodes, func(node string) (string, int) { return node, 3 }), crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+	assert.NoError(t, verifySameTable(mh, wmh))
+}
+
+// TestRandomAddAndRemove adds and removes random nodes, and checks that the
+// number of moved slots is at most twice the minimum, i.e., the number of slots
+// the added node gains or the removed node loses.
+func TestRandomAddAndRemove(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	slotCnt := 10_007
+	nodes := lo.Times(10, func(i int) string { return fmt.Sprintf("B%d", i) })
+	mh, err := maglev_hash.NewMaglevWithTableSize(slotCnt, nodes, crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		var next *maglev_hash.MaglevHash
+		var minMoves int
+		if len(nodes) == 1 || (len(nodes) < 30 && r.Intn(2) == 0) {
+			node := fmt.Sprintf("N%d", i)
+			next, err = mh.AddNode(node)
+			assert.NoError(t, err)
+			nodes = append(nodes, node)
+			minMoves = len(next.SlotsOf(node))
+		} else {
+			j := r.Intn(len(nodes))
+			next, err = mh.RemoveNode(nodes[j])
+			assert.NoError(t, err)
+			minMoves = len(mh.SlotsOf(nodes[j]))
+			nodes = append(nodes[:j], nodes[j+1:]...)
+		}
+		assert.NoError(t, verifyInvariants(next, nil))
+
+		// The table is the same as the one built from scratch.
+		fresh, err := maglev_hash.NewMaglevWithTableSize(slotCnt, nodes, crc32.ChecksumIEEE)
+		assert.NoError(t, err)
+		assert.NoError(t, verifySameTable(fresh, next))
+
+		diff, err := maglev_hash.Diff(mh, next)
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, len(diff.Moves), 2*minMoves, "step %d, %d nodes", i, len(nodes))
+		mh = next
+	}
+}
+
+// FuzzNewMaglev builds tables from comma-separated node names.
+func FuzzNewMaglev(f *testing.F) {
+	f.Add("B0,B1,B2")
+	f.Add("B0,B0,B1")
+	f.Add("")
+	f.Add("a,b,c,d,e,f,g,h,i,j,k,l,m,n,o,p,q,r,s,t")
+	f.Add("10.0.0.1:80,10.0.0.2:80,10.0.0.3:80,10.0.0.4:80")
+
+	slotCnt := 251
+	f.Fuzz(func(t *testing.T, s string) {
+		nodes := lo.Uniq(lo.Compact(strings.Split(s, ",")))
+		mh, err := maglev_hash.NewMaglevWithTableSize(slotCnt, nodes, crc32.ChecksumIEEE)
+		if len(nodes) == 0 || len(nodes) > slotCnt {
+			assert.Error(t, err)
+			return
+		}
+		assert.NoError(t, err)
+		assert.NoError(t, verifyInvariants(mh, nil))
+
+		// Order and duplicates of nodes do not matter.
+		reversed := lo.Reverse(append(append([]string{}, nodes...), nodes[0]))
+		mh2, err := maglev_hash.NewMaglevWithTableSize(slotCnt, reversed, crc32.ChecksumIEEE)
+		assert.NoError(t, err)
+		assert.NoError(t, verifySameTable(mh, mh2))
+	})
+}
+
+func BenchmarkNewMaglev(b *testing.B) {
+	for _, nodeCnt := range []int{10, 100, 1000} {
+		nodes := lo.Times(nodeCnt, func(i int) string { return fmt.Sprintf("B%d", i) })
+		b.Run(fmt.Sprintf("nodes=%d/slots=65537", nodeCnt), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := maglev_hash.NewMaglevWithTableSize(65537, nodes, crc32.ChecksumIEEE); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkAddNode(b *testing.B) {
+	nodes := lo.Times(100, func(i int) string { return fmt.Sprintf("B%d", i) })
+	mh, err := maglev_hash.NewMaglevWithTableSize(65537, nodes, crc32.ChecksumIEEE)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := mh.AddNode("B100"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkNode compares Node with a baseline lookup from a plain slice of node
+// indexes, which is the least work a lookup has to do. The gap between the two
+// is the overhead of the table representation.
+func BenchmarkNode(b *testing.B) {
+	nodes := lo.Times(100, func(i int) string { return fmt.Sprintf("B%d", i) })
+	mh, err := maglev_hash.NewMaglevWithTableSize(65537, nodes, crc32.ChecksumIEEE)
+	if err != nil {
+		b.Fatal(err)
+	}
+	keys := lo.Times(1024, func(i int) []byte { return []byte(fmt.Sprintf("key-%d", i)) })
+
+	b.Run("maglev", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			mh.Node(keys[i%len(keys)])
+		}
+	})
+
+	b.Run("baseline", func(b *testing.B) {
+		sortedNodes := mh.Nodes()
+		index := lo.SliceToMap(sortedNodes, func(node string) (string, int) {
+			return node, slices.Index(sortedNodes, node)
+		})
+		lookup := lo.Times(mh.SlotCnt(), func(slot int) int { return index[mh.NodeOfSlot(slot)] })
+		slotCnt := uint32(mh.SlotCnt())
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_ = sortedNodes[lookup[crc32.ChecksumIEEE(keys[i%len(keys)])%slotCnt]]
+		}
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"hash/crc32"
 	"math"
+	"sort"
 	"strconv"
 	"testing"
 
@@ -92,6 +93,277 @@ func TestLoadBalanceAndDisruption(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestWeightedLoadBalanceAndDisruption(t *testing.T) {
+	slotCnt := 10_007
+	keySpaceCnt := 1_000_000
+	weights := map[string]int{"B0": 1, "B1": 2, "B2": 3, "B3": 4}
+	totalWeight := 10
+
+	_, err := maglev_hash.NewWeightedMaglevWithTableSize(slotCnt, map[string]int{"B0": 1, "B1": 0}, crc32.ChecksumIEEE)
+	assert.Error(t, err)
+	// weights are capped so that building the table does not overflow.
+	_, err = maglev_hash.NewWeightedMaglevWithTableSize(slotCnt, map[string]int{"B0": 1, "B1": 1 << 62}, crc32.ChecksumIEEE)
+	assert.Error(t, err)
+	capped, err := maglev_hash.NewWeightedMaglevWithTableSize(slotCnt, map[string]int{"B0": 1, "B1": maglev_hash.MaxWeight}, crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+	assert.Len(t, capped.SlotsOf("B1"), slotCnt)
+	// B0 owns no slot, so it is never a fallback.
+	assert.Equal(t, []string{"B1"}, capped.NodesN([]byte("key"), 2))
+	_, err = capped.AddWeightedNode("B2", maglev_hash.MaxWeight+1)
+	assert.Error(t, err)
+
+	mh, err := maglev_hash.NewWeightedMaglevWithTableSize(slotCnt, weights, crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+
+	keyToNode1 := make(map[int]string)
+	nodeLoad1 := make(map[string]int)
+	for i := 0; i < keySpaceCnt; i++ {
+		node := mh.Node([]byte(strconv.FormatInt(int64(i), 10)))
+		keyToNode1[i] = node
+		nodeLoad1[node]++
+	}
+	// each node's load should be within 5% of its share of the key space.
+	err = verifyWeightedLoadBalance(nodeLoad1, weights, totalWeight, keySpaceCnt, 0.05)
+	assert.NoError(t, err)
+
+	// double the weight of B0. Only slots moving to B0 are disrupted, about 1/11 of key space.
+	weights["B0"] = 2
+	totalWeight = 11
+	mh, err = maglev_hash.NewWeightedMaglevWithTableSize(slotCnt, weights, crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+
+	keyToNode2 := make(map[int]string)
+	nodeLoad2 := make(map[string]int)
+	for i := 0; i < keySpaceCnt; i++ {
+		node := mh.Node([]byte(strconv.FormatInt(int64(i), 10)))
+		keyToNode2[i] = node
+		nodeLoad2[node]++
+	}
+	err = verifyWeightedLoadBalance(nodeLoad2, weights, totalWeight, keySpaceCnt, 0.05)
+	assert.NoError(t, err)
+	err = verifyDisruption(keySpaceCnt, keyToNode1, keyToNode2, 2*keySpaceCnt/totalWeight)
+	assert.NoError(t, err)
+}
+
+func TestAddAndRemoveNode(t *testing.T) {
+	slotCnt := 10_007
+	nodes := lo.Times(10, func(i int) string {
+		return fmt.Sprintf("B%d", i)
+	})
+	mh, err := maglev_hash.NewMaglevWithTableSize(slotCnt, nodes, crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+
+	_, err = mh.AddNode("B0")
+	assert.Error(t, err)
+	_, err = mh.AddWeightedNode("B10", 0)
+	assert.Error(t, err)
+	_, err = mh.RemoveNode("B10")
+	assert.Error(t, err)
+
+	// adding a node is the same as building from scratch.
+	added, err := mh.AddNode("B10")
+	assert.NoError(t, err)
+	expected, err := maglev_hash.NewMaglevWithTableSize(slotCnt, append(nodes, "B10"), crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+	assert.NoError(t, verifySameAssignment(expected, added, 100_000))
+	assert.Equal(t, expected.Nodes(), added.Nodes())
+	assert.Contains(t, added.Nodes(), "B10")
+
+	// removing a node is the same as building from scratch.
+	removed, err := added.RemoveNode("B5")
+	assert.NoError(t, err)
+	expected, err = maglev_hash.NewMaglevWithTableSize(slotCnt, lo.Without(append(nodes, "B10"), "B5"), crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+	assert.NoError(t, verifySameAssignment(expected, removed, 100_000))
+
+	// adding a weighted node is the same as building from scratch.
+	weighted, err := removed.AddWeightedNode("B5", 3)
+	assert.NoError(t, err)
+	weights := lo.SliceToMap(nodes, func(node string) (string, int) { return node, 1 })
+	weights["B10"] = 1
+	weights["B5"] = 3
+	expected, err = maglev_hash.NewWeightedMaglevWithTableSize(slotCnt, weights, crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+	assert.NoError(t, verifySameAssignment(expected, weighted, 100_000))
+
+	// the original table is not changed.
+	expected, err = maglev_hash.NewMaglevWithTableSize(slotCnt, nodes, crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+	assert.NoError(t, verifySameAssignment(expected, mh, 100_000))
+
+	single, err := maglev_hash.NewMaglevWithTableSize(7, []string{"B0"}, crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+	_, err = single.RemoveNode("B0")
+	assert.Error(t, err)
+}
+
+func verifySameAssignment(mh1, mh2 *maglev_hash.MaglevHash, keySpaceCnt int) error {
+	for i := 0; i < keySpaceCnt; i++ {
+		key := []byte(strconv.FormatInt(int64(i), 10))
+		if mh1.Node(key) != mh2.Node(key) {
+			return fmt.Errorf("key %d is assigned to different nodes, %s != %s", i, mh1.Node(key), mh2.Node(key))
+		}
+	}
+	return nil
+}
+
+func TestNodesN(t *testing.T) {
+	nodes := lo.Times(10, func(i int) string {
+		return fmt.Sprintf("B%d", i)
+	})
+	mh, err := maglev_hash.NewMaglevWithTableSize(1009, nodes, crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+	// the same table built from nodes in a different order.
+	mh2, err := maglev_hash.NewMaglevWithTableSize(1009, lo.Reverse(append([]string{}, nodes...)), crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+
+	assert.Empty(t, mh.NodesN([]byte("key"), 0))
+	for i := 0; i < 1000; i++ {
+		key := []byte(strconv.Itoa(i))
+		result := mh.NodesN(key, 3)
+		assert.Len(t, result, 3)
+		assert.Equal(t, mh.Node(key), result[0])
+		assert.Equal(t, 3, len(lo.Uniq(result)))
+		assert.Equal(t, result, mh2.NodesN(key, 3))
+		// a shorter list is a prefix of a longer list.
+		assert.Equal(t, result, mh.NodesN(key, 5)[:3])
+	}
+
+	// no more than the number of nodes.
+	result := mh.NodesN([]byte("key"), 20)
+	assert.ElementsMatch(t, nodes, result)
+}
+
+func TestPermutationHashOptions(t *testing.T) {
+	slotKeyHash := func(b []byte) uint32 {
+		i, _ := strconv.Atoi(string(b))
+		return uint32(i)
+	}
+	md5Options := maglev_hash.Options{PermutationHashFn: maglev_hash.MD5PermutationHash}
+
+	// MD5PermutationHash reproduces the table built before options were introduced.
+	mh, err := maglev_hash.NewMaglevWithTableSize(13, []string{"B0", "B1", "B2"}, slotKeyHash, md5Options)
+	assert.NoError(t, err)
+	expected := []string{"B1", "B0", "B1", "B1", "B0", "B1", "B2", "B0", "B2", "B2", "B0", "B2", "B0"}
+	for i, node := range expected {
+		assert.Equal(t, node, mh.Node([]byte(strconv.Itoa(i))))
+	}
+
+	// the permutation hash function is kept after membership changes.
+	nodes := lo.Times(10, func(i int) string {
+		return fmt.Sprintf("B%d", i)
+	})
+	mh, err = maglev_hash.NewMaglevWithTableSize(1009, nodes, crc32.ChecksumIEEE, md5Options)
+	assert.NoError(t, err)
+	added, err := mh.AddNode("B10")
+	assert.NoError(t, err)
+	rebuilt, err := maglev_hash.NewMaglevWithTableSize(1009, append(nodes, "B10"), crc32.ChecksumIEEE, md5Options)
+	assert.NoError(t, err)
+	assert.Equal(t, rebuilt.Checksum(), added.Checksum())
+
+	// invalid permutations are rejected.
+	_, err = maglev_hash.NewMaglevWithTableSize(13, []string{"B0"}, crc32.ChecksumIEEE, maglev_hash.Options{
+		PermutationHashFn: func(node string, slotCnt int) (int, int) { return 0, 0 },
+	})
+	assert.Error(t, err)
+
+	// the default permutation hash function does not allocate.
+	allocs := testing.AllocsPerRun(100, func() {
+		maglev_hash.FastPermutationHash("B0", 65_537)
+	})
+	assert.Zero(t, allocs)
+}
+
+func TestAutoSizedMaglev(t *testing.T) {
+	_, err := maglev_hash.SlotCntFor(0, 0.01)
+	assert.Error(t, err)
+	_, err = maglev_hash.SlotCntFor(10, 0)
+	assert.Error(t, err)
+	slotCnt, err := maglev_hash.SlotCntFor(100, 0.01)
+	assert.NoError(t, err)
+	assert.Equal(t, 10_007, slotCnt)
+	slotCnt, err = maglev_hash.SlotCntFor(3, 0.5)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, slotCnt)
+	// there are at least as many slots as nodes, however loose the imbalance is.
+	slotCnt, err = maglev_hash.SlotCntFor(10, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 11, slotCnt)
+	slotCnt, err = maglev_hash.SlotCntFor(13, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, 13, slotCnt)
+
+	nodes := lo.Times(10, func(i int) string {
+		return fmt.Sprintf("B%d", i)
+	})
+	mh, err := maglev_hash.NewAutoSizedMaglev(nodes, 0, 2, crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+	assert.Equal(t, 11, mh.SlotCnt())
+
+	mh, err = maglev_hash.NewAutoSizedMaglev(nodes, 100, 0.01, crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+	assert.InDelta(t, 10.0/10_007, mh.ExpectedImbalance(), 1e-9)
+
+	// the table has room for 100 nodes.
+	regrown, err := mh.Regrow(0.01)
+	assert.NoError(t, err)
+	assert.Same(t, mh, regrown)
+
+	// the fleet outgrows the table.
+	for i := 10; i < 200; i++ {
+		mh, err = mh.AddNode(fmt.Sprintf("B%d", i))
+		assert.NoError(t, err)
+	}
+	assert.Greater(t, mh.ExpectedImbalance(), 0.01)
+	regrown, err = mh.Regrow(0.01)
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, regrown.ExpectedImbalance(), 0.01)
+	assert.Equal(t, mh.Nodes(), regrown.Nodes())
+	_, err = mh.Regrow(0)
+	assert.Error(t, err)
+
+	// the imbalance of a weighted table is relative to the lightest node.
+	weighted, err := maglev_hash.NewWeightedMaglevWithTableSize(1009, map[string]int{"B0": 1, "B1": 9}, crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+	assert.InDelta(t, 10.0/1009, weighted.ExpectedImbalance(), 1e-9)
+	regrown, err = weighted.Regrow(0.001)
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, regrown.ExpectedImbalance(), 0.001)
+}
+
+func TestSlotOwnership(t *testing.T) {
+	nodes := lo.Times(10, func(i int) string {
+		return fmt.Sprintf("B%d", i)
+	})
+	mh, err := maglev_hash.NewMaglevWithTableSize(1009, nodes, crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+	assert.Equal(t, 1009, mh.SlotCnt())
+
+	for i := 0; i < 1000; i++ {
+		key := []byte(strconv.Itoa(i))
+		slot := mh.Slot(key)
+		assert.Equal(t, int(crc32.ChecksumIEEE(key)%1009), slot)
+		assert.Equal(t, mh.Node(key), mh.NodeOfSlot(slot))
+	}
+
+	ownership := mh.SlotOwnership()
+	assert.Len(t, ownership, 10)
+	slotCnt := 0
+	for _, node := range nodes {
+		slots := mh.SlotsOf(node)
+		assert.Equal(t, slots, ownership[node])
+		assert.True(t, sort.IntsAreSorted(slots))
+		// each node owns 100 or 101 slots.
+		assert.InDelta(t, 100, len(slots), 1)
+		for _, slot := range slots {
+			assert.Equal(t, node, mh.NodeOfSlot(slot))
+		}
+		slotCnt += len(slots)
+	}
+	assert.Equal(t, 1009, slotCnt)
+	assert.Nil(t, mh.SlotsOf("B10"))
+}
+
 func verifyDisruption(keyspaceCnt int, keyToNode1, keyToNode2 map[int]string, threshold int) error {
 	// number of keys that have been moved.
 	moveCnt := 0
@@ -119,3 +391,14 @@ func verifyEvenLoadBalance(load map[string]int, threshold int) error {
 	}
 	return nil
 }
+
+func verifyWeightedLoadBalance(load map[string]int, weights map[string]int, totalWeight, keySpaceCnt int, tolerance float64) error {
+	for node, w := range weights {
+		expected := float64(keySpaceCnt) * float64(w) / float64(totalWeight)
+		fmt.Printf("node: %s load: %d expected: %.0f\n", node, load[node], expected)
+		if math.Abs(float64(load[node])-expected) > expected*tolerance {
+			return fmt.Errorf("load of node %s is not proportional to its weight, %d vs %.0f", node, load[node], expected)
+		}
+	}
+	return nil
+}
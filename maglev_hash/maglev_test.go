@@ -9,7 +9,7 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-func TestBuildPreferences(t *testing.T) {
+func TestBuildPermutations(t *testing.T) {
 	cases := []struct {
 		slotCnt int
 		nodeCnt int
@@ -26,10 +26,12 @@ func TestBuildPreferences(t *testing.T) {
 					return fmt.Sprintf("B%d", i)
 				}),
 			}
-			prefList := mh.buildPreferences()
+			perms, err := mh.buildPermutations()
+			assert.NoError(t, err)
 			expectedListAfterSort := lo.Times(tc.slotCnt, func(index int) int { return index })
-			assert.Equal(t, len(prefList), tc.nodeCnt)
-			for _, l := range prefList {
+			assert.Equal(t, len(perms), tc.nodeCnt)
+			for _, p := range perms {
+				l := lo.Times(tc.slotCnt, func(j int) int { return p.at(j, tc.slotCnt) })
 				newList := lo.Uniq(l)
 				sort.Ints(newList)
 				assert.Equal(t, expectedListAfterSort, newList)
@@ -45,15 +47,18 @@ func TestBuildLookup_1(t *testing.T) {
 		nodes:   []string{"B0", "B1", "B2"},
 	}
 
-	lookup := m.buildLookup(
-		[][]int{
-			[]int{3, 0, 4, 1, 5, 2, 6},
-			[]int{0, 2, 4, 6, 1, 3, 5},
-			[]int{3, 4, 5, 6, 0, 1, 2},
-		})
+	// preference lists:
+	// B0: 3, 0, 4, 1, 5, 2, 6
+	// B1: 0, 2, 4, 6, 1, 3, 5
+	// B2: 3, 4, 5, 6, 0, 1, 2
+	lookup := m.buildLookup([]permutation{
+		{offset: 3, skip: 4},
+		{offset: 0, skip: 2},
+		{offset: 3, skip: 1},
+	})
 
 	// slot assignment: B1, B0, B1, B0, B2, B2, B0
-	assert.Equal(t, []int{1, 0, 1, 0, 2, 2, 0}, lookup)
+	assert.Equal(t, []int{1, 0, 1, 0, 2, 2, 0}, lo.Times(7, lookup.at))
 }
 
 func TestBuildLookup_2(t *testing.T) {
@@ -65,11 +70,25 @@ func TestBuildLookup_2(t *testing.T) {
 		},
 	}
 
-	lookup := m.buildLookup([][]int{
-		[]int{3, 0, 4, 1, 5, 2, 6},
-		[]int{3, 4, 5, 6, 0, 1, 2},
+	// preference lists:
+	// B0: 3, 0, 4, 1, 5, 2, 6
+	// B2: 3, 4, 5, 6, 0, 1, 2
+	lookup := m.buildLookup([]permutation{
+		{offset: 3, skip: 4},
+		{offset: 3, skip: 1},
 	})
 
 	// slot assignment: B0, B0, B0, B0, B2, B2, B2
-	assert.Equal(t, []int{0, 0, 0, 0, 1, 1, 1}, lookup)
+	assert.Equal(t, []int{0, 0, 0, 0, 1, 1, 1}, lo.Times(7, lookup.at))
+}
+
+func TestNewSlotTable(t *testing.T) {
+	assert.Equal(t, 1, newSlotTable(7, 256).width)
+	assert.Equal(t, 2, newSlotTable(7, 257).width)
+	assert.Equal(t, 2, newSlotTable(7, 65_536).width)
+	assert.Equal(t, 4, newSlotTable(7, 65_537).width)
+
+	lookup := newSlotTable(3, 65_537)
+	lookup.set(2, 65_536)
+	assert.Equal(t, 65_536, lookup.at(2))
 }
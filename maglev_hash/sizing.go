@@ -0,0 +1,86 @@
+package maglev_hash
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/pengubco/algorithms/prime"
+	"github.com/samber/lo"
+)
+
+// ExpectedImbalance returns the expected imbalance of a Maglev hash of nodeCnt
+// nodes and slotCnt slots. Nodes take slots in turns, so any two nodes own
+// numbers of slots differing by at most one. Relative to the average number of
+// slots per node, slotCnt/nodeCnt, the imbalance is nodeCnt/slotCnt.
+func ExpectedImbalance(nodeCnt, slotCnt int) float64 {
+	return float64(nodeCnt) / float64(slotCnt)
+}
+
+// SlotCntFor returns the smallest prime number of slots such that a Maglev hash
+// of nodeCnt nodes has an expected imbalance of at most maxImbalance. For
+// example, 100 nodes and 1% imbalance need 10007 slots. The result is at least
+// nodeCnt, because every node must own a slot, even if maxImbalance is larger
+// than 1.
+func SlotCntFor(nodeCnt int, maxImbalance float64) (int, error) {
+	if nodeCnt <= 0 {
+		return 0, fmt.Errorf("number of nodes must be positive, %d", nodeCnt)
+	}
+	if maxImbalance <= 0 {
+		return 0, fmt.Errorf("max imbalance must be positive, %f", maxImbalance)
+	}
+	slotCnt := max(float64(nodeCnt), math.Ceil(float64(nodeCnt)/maxImbalance))
+	if slotCnt > math.MaxUint32 {
+		return 0, fmt.Errorf("too many slots for %d nodes and max imbalance %f", nodeCnt, maxImbalance)
+	}
+	return prime.NextPrime(int(slotCnt) - 1), nil
+}
+
+// NewAutoSizedMaglev creates a Maglev hash whose number of slots is picked by
+// SlotCntFor, for expectedNodeCnt nodes or the number of given nodes, whichever
+// is larger. Sizing for the expected fleet avoids regrowing the table, which
+// moves most keys, as nodes are added.
+func NewAutoSizedMaglev(nodes []string, expectedNodeCnt int, maxImbalance float64, keyHashFn KeyHashFnType, opts ...Options) (*MaglevHash, error) {
+	nodes = lo.Uniq(nodes)
+	sort.Strings(nodes)
+	slotCnt, err := SlotCntFor(max(len(nodes), expectedNodeCnt), maxImbalance)
+	if err != nil {
+		return nil, err
+	}
+	return newMaglev(slotCnt, nodes, nil, nil, keyHashFn, optionsOf(opts))
+}
+
+// ExpectedImbalance returns the expected imbalance of the table. For weighted
+// nodes, the imbalance is relative to the node of the smallest weight, which
+// owns the fewest slots.
+func (m *MaglevHash) ExpectedImbalance() float64 {
+	return ExpectedImbalance(m.effectiveNodeCnt(), m.slotCnt)
+}
+
+// Regrow returns a Maglev hash of the same nodes with more slots if the
+// expected imbalance of the table exceeds maxImbalance, e.g. after the fleet
+// outgrows the table. Otherwise, it returns the table itself. Regrowing changes
+// the number of slots, so most keys move to different nodes.
+func (m *MaglevHash) Regrow(maxImbalance float64) (*MaglevHash, error) {
+	if maxImbalance <= 0 {
+		return nil, fmt.Errorf("max imbalance must be positive, %f", maxImbalance)
+	}
+	if m.ExpectedImbalance() <= maxImbalance {
+		return m, nil
+	}
+	slotCnt, err := SlotCntFor(m.effectiveNodeCnt(), maxImbalance)
+	if err != nil {
+		return nil, err
+	}
+	// permutations depend on the number of slots, so they are computed again.
+	return newMaglev(slotCnt, m.nodes, m.weights, nil, m.keyHashFn, m.options())
+}
+
+// effectiveNodeCnt returns the number of nodes of weight 1 that has the same
+// imbalance as the table, which is the total weight divided by the smallest weight.
+func (m *MaglevHash) effectiveNodeCnt() int {
+	if m.weights == nil {
+		return m.nodeCnt
+	}
+	return int(math.Ceil(float64(lo.Sum(m.weights)) / float64(lo.Min(m.weights))))
+}
@@ -0,0 +1,158 @@
+package maglev_hash
+
+import (
+	"fmt"
+	"slices"
+)
+
+// TypedMaglev is a Maglev hash over nodes of any type, e.g. a backend struct
+// with address, zone and weight. Each node has a stable identity from nodeID,
+// which is what the underlying MaglevHash hashes, so the table only depends on
+// identities and weights, not on other fields of nodes. TypedMaglev is immutable.
+//
+// Example
+//
+//	type Backend struct {
+//		Name   string
+//		Addr   string
+//		Weight int
+//	}
+//
+//	tm, _ := NewTypedMaglev(DefaultSlotCnt, backends,
+//		func(b *Backend) string { return b.Name },
+//		func(b *Backend) int { return b.Weight },
+//		crc32.ChecksumIEEE)
+//	backend := tm.Node([]byte("key1"))
+type TypedMaglev[N any] struct {
+	mh *MaglevHash
+
+	// nodes[i] is the node whose identity is mh.nodes[i].
+	nodes []N
+
+	nodeID     func(N) string
+	nodeWeight func(N) int
+}
+
+// NewTypedMaglev creates a Maglev hash of slotCnt slots over the given nodes.
+// nodeID returns the identity of a node, and nodes must have distinct
+// identities. nodeWeight returns the weight of a node, nil means all nodes have
+// the same weight.
+func NewTypedMaglev[N any](slotCnt int, nodes []N, nodeID func(N) string, nodeWeight func(N) int,
+	keyHashFn KeyHashFnType, opts ...Options) (*TypedMaglev[N], error) {
+	byID := make(map[string]N, len(nodes))
+	weights := make(map[string]int, len(nodes))
+	for _, node := range nodes {
+		id := nodeID(node)
+		if _, ok := byID[id]; ok {
+			return nil, fmt.Errorf("duplicate node, %s", id)
+		}
+		byID[id] = node
+		weights[id] = 1
+		if nodeWeight != nil {
+			weights[id] = nodeWeight(node)
+		}
+	}
+	var mh *MaglevHash
+	var err error
+	if nodeWeight == nil {
+		ids := make([]string, 0, len(byID))
+		for id := range byID {
+			ids = append(ids, id)
+		}
+		mh, err = NewMaglevWithTableSize(slotCnt, ids, keyHashFn, opts...)
+	} else {
+		mh, err = NewWeightedMaglevWithTableSize(slotCnt, weights, keyHashFn, opts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newTypedMaglev(mh, byID, nodeID, nodeWeight), nil
+}
+
+// newTypedMaglev creates a TypedMaglev from a table and nodes by identity.
+func newTypedMaglev[N any](mh *MaglevHash, byID map[string]N, nodeID func(N) string, nodeWeight func(N) int) *TypedMaglev[N] {
+	t := &TypedMaglev[N]{
+		mh:         mh,
+		nodes:      make([]N, mh.nodeCnt),
+		nodeID:     nodeID,
+		nodeWeight: nodeWeight,
+	}
+	for i, id := range mh.nodes {
+		t.nodes[i] = byID[id]
+	}
+	return t
+}
+
+// Table returns the underlying Maglev hash of node identities.
+func (t *TypedMaglev[N]) Table() *MaglevHash {
+	return t.mh
+}
+
+// Node returns the assigned node for the given key.
+func (t *TypedMaglev[N]) Node(key []byte) N {
+	return t.nodes[t.mh.lookup.at(t.mh.Slot(key))]
+}
+
+// NodesN returns n distinct nodes for the given key in the order of preference.
+// See MaglevHash.NodesN.
+func (t *TypedMaglev[N]) NodesN(key []byte, n int) []N {
+	result := make([]N, 0, min(n, t.mh.nodeCnt))
+	if n <= 0 {
+		return result
+	}
+	t.mh.walk(t.mh.Slot(key), func(i int) bool {
+		result = append(result, t.nodes[i])
+		return len(result) < n
+	})
+	return result
+}
+
+// NodeByID returns the node of the given identity.
+func (t *TypedMaglev[N]) NodeByID(id string) (N, bool) {
+	i, ok := t.mh.nodeIndex(id)
+	if !ok {
+		var empty N
+		return empty, false
+	}
+	return t.nodes[i], true
+}
+
+// Nodes returns nodes in the order of identity.
+func (t *TypedMaglev[N]) Nodes() []N {
+	return slices.Clone(t.nodes)
+}
+
+// AddNode returns a new TypedMaglev with the given node added. See
+// MaglevHash.AddWeightedNode.
+func (t *TypedMaglev[N]) AddNode(node N) (*TypedMaglev[N], error) {
+	weight := 1
+	if t.nodeWeight != nil {
+		weight = t.nodeWeight(node)
+	}
+	mh, err := t.mh.AddWeightedNode(t.nodeID(node), weight)
+	if err != nil {
+		return nil, err
+	}
+	byID := t.byID()
+	byID[t.nodeID(node)] = node
+	return newTypedMaglev(mh, byID, t.nodeID, t.nodeWeight), nil
+}
+
+// RemoveNode returns a new TypedMaglev with the node of the given identity
+// removed. See MaglevHash.RemoveNode.
+func (t *TypedMaglev[N]) RemoveNode(id string) (*TypedMaglev[N], error) {
+	mh, err := t.mh.RemoveNode(id)
+	if err != nil {
+		return nil, err
+	}
+	return newTypedMaglev(mh, t.byID(), t.nodeID, t.nodeWeight), nil
+}
+
+// byID returns nodes by identity.
+func (t *TypedMaglev[N]) byID() map[string]N {
+	result := make(map[string]N, len(t.nodes))
+	for i, id := range t.mh.nodes {
+		result[id] = t.nodes[i]
+	}
+	return result
+}
@@ -0,0 +1,85 @@
+package maglev_hash_test
+
+import (
+	"fmt"
+	"hash/crc32"
+	"strconv"
+	"testing"
+
+	"github.com/pengubco/algorithms/maglev_hash"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+)
+
+type backend struct {
+	name   string
+	addr   string
+	weight int
+}
+
+func backendName(b *backend) string {
+	return b.name
+}
+
+func backendWeight(b *backend) int {
+	return b.weight
+}
+
+func TestTypedMaglev(t *testing.T) {
+	backends := lo.Times(10, func(i int) *backend {
+		return &backend{
+			name:   fmt.Sprintf("B%d", i),
+			addr:   fmt.Sprintf("10.0.0.%d:80", i),
+			weight: 1 + i%3,
+		}
+	})
+	tm, err := maglev_hash.NewTypedMaglev(1009, backends, backendName, backendWeight, crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+
+	// the table is the same as the one built from names and weights.
+	mh, err := maglev_hash.NewWeightedMaglevWithTableSize(1009,
+		lo.SliceToMap(backends, func(b *backend) (string, int) { return b.name, b.weight }), crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+	assert.Equal(t, mh.Checksum(), tm.Table().Checksum())
+	for i := 0; i < 1000; i++ {
+		key := []byte(strconv.Itoa(i))
+		b := tm.Node(key)
+		assert.Equal(t, mh.Node(key), b.name)
+		assert.Equal(t, mh.NodesN(key, 3), lo.Map(tm.NodesN(key, 3), func(b *backend, _ int) string { return b.name }))
+	}
+
+	b, ok := tm.NodeByID("B3")
+	assert.True(t, ok)
+	assert.Same(t, backends[3], b)
+	_, ok = tm.NodeByID("B10")
+	assert.False(t, ok)
+	assert.ElementsMatch(t, backends, tm.Nodes())
+
+	added, err := tm.AddNode(&backend{name: "B10", addr: "10.0.0.10:80", weight: 2})
+	assert.NoError(t, err)
+	b, ok = added.NodeByID("B10")
+	assert.True(t, ok)
+	assert.Equal(t, "10.0.0.10:80", b.addr)
+	_, err = added.AddNode(&backend{name: "B10", weight: 1})
+	assert.Error(t, err)
+
+	removed, err := added.RemoveNode("B3")
+	assert.NoError(t, err)
+	_, ok = removed.NodeByID("B3")
+	assert.False(t, ok)
+	assert.Len(t, removed.Nodes(), 10)
+	_, err = removed.RemoveNode("B3")
+	assert.Error(t, err)
+}
+
+func TestTypedMaglev_Unweighted(t *testing.T) {
+	backends := []*backend{{name: "B0"}, {name: "B1"}}
+	tm, err := maglev_hash.NewTypedMaglev(7, backends, backendName, nil, crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+	mh, err := maglev_hash.NewMaglevWithTableSize(7, []string{"B0", "B1"}, crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+	assert.Equal(t, mh.Checksum(), tm.Table().Checksum())
+
+	_, err = maglev_hash.NewTypedMaglev(7, []*backend{{name: "B0"}, {name: "B0"}}, backendName, nil, crc32.ChecksumIEEE)
+	assert.Error(t, err)
+}
@@ -0,0 +1,111 @@
+package maglev_hash
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ZoneAwareMaglev routes keys to nodes in the caller's zone. Each zone has its
+// own Maglev table, so keys spread evenly over nodes in the zone. A cross-zone
+// table of all nodes serves callers whose zone is unknown, empty, or has no
+// healthy node. Lookups are deterministic: the same key from the same zone goes
+// to the same node as long as membership and health do not change.
+// It is safe for concurrent use.
+//
+// Example
+// z, _ := NewZoneAwareMaglev(DefaultSlotCnt, map[string][]string{
+// "us-east-1a": {"B0", "B1"},
+// "us-east-1b": {"B2", "B3"},
+// }, crc32.ChecksumIEEE)
+// node, ok := z.Node([]byte("key1"), "us-east-1a")
+type ZoneAwareMaglev struct {
+	// zones[zone] is the table of nodes in the zone. Zones without nodes have no table.
+	zones map[string]*HealthAwareMaglev
+
+	// The cross-zone table of all nodes.
+	global *HealthAwareMaglev
+
+	// zoneOf[node] is the zone of the node.
+	zoneOf map[string]string
+}
+
+// NewZoneAwareMaglev creates a ZoneAwareMaglev from nodes in each zone. Each
+// table has slotCnt slots. A node must belong to one zone only.
+func NewZoneAwareMaglev(slotCnt int, zoneNodes map[string][]string, keyHashFn KeyHashFnType, opts ...Options) (*ZoneAwareMaglev, error) {
+	z := &ZoneAwareMaglev{
+		zones:  make(map[string]*HealthAwareMaglev),
+		zoneOf: make(map[string]string),
+	}
+	// visit zones in order so that errors are deterministic.
+	zones := make([]string, 0, len(zoneNodes))
+	for zone := range zoneNodes {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+	var allNodes []string
+	for _, zone := range zones {
+		nodes := zoneNodes[zone]
+		for _, node := range nodes {
+			if other, ok := z.zoneOf[node]; ok && other != zone {
+				return nil, fmt.Errorf("node %s belongs to two zones, %s and %s", node, other, zone)
+			}
+			z.zoneOf[node] = zone
+		}
+		if len(nodes) == 0 {
+			continue
+		}
+		mh, err := NewMaglevWithTableSize(slotCnt, nodes, keyHashFn, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("zone %s: %w", zone, err)
+		}
+		z.zones[zone] = NewHealthAwareMaglev(mh)
+		allNodes = append(allNodes, nodes...)
+	}
+	mh, err := NewMaglevWithTableSize(slotCnt, allNodes, keyHashFn, opts...)
+	if err != nil {
+		return nil, err
+	}
+	z.global = NewHealthAwareMaglev(mh)
+	return z, nil
+}
+
+// Node returns the assigned healthy node for the given key from the caller's
+// zone. If the zone is unknown, empty or has no healthy node, it falls back to
+// the cross-zone table. It returns false if no node is healthy.
+func (z *ZoneAwareMaglev) Node(key []byte, zone string) (string, bool) {
+	if t, ok := z.zones[zone]; ok {
+		if node, ok := t.Node(key); ok {
+			return node, true
+		}
+	}
+	return z.global.Node(key)
+}
+
+// Zone returns the zone of the node.
+func (z *ZoneAwareMaglev) Zone(node string) (string, bool) {
+	zone, ok := z.zoneOf[node]
+	return zone, ok
+}
+
+// MarkDown marks the node unhealthy in both its zone and the cross-zone table.
+// It returns error if the node does not exist.
+func (z *ZoneAwareMaglev) MarkDown(node string) error {
+	return z.mark(node, (*HealthAwareMaglev).MarkDown)
+}
+
+// MarkUp marks the node healthy in both its zone and the cross-zone table. It
+// returns error if the node does not exist.
+func (z *ZoneAwareMaglev) MarkUp(node string) error {
+	return z.mark(node, (*HealthAwareMaglev).MarkUp)
+}
+
+func (z *ZoneAwareMaglev) mark(node string, markFn func(*HealthAwareMaglev, string) error) error {
+	zone, ok := z.zoneOf[node]
+	if !ok {
+		return fmt.Errorf("node does not exist, %s", node)
+	}
+	if err := markFn(z.zones[zone], node); err != nil {
+		return err
+	}
+	return markFn(z.global, node)
+}
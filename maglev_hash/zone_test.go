@@ -0,0 +1,83 @@
+package maglev_hash_test
+
+import (
+	"hash/crc32"
+	"strconv"
+	"testing"
+
+	"github.com/pengubco/algorithms/maglev_hash"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZoneAwareMaglev(t *testing.T) {
+	zoneNodes := map[string][]string{
+		"a": {"A0", "A1", "A2", "A3"},
+		"b": {"B0", "B1", "B2", "B3"},
+		"c": {},
+	}
+	_, err := maglev_hash.NewZoneAwareMaglev(1009, map[string][]string{
+		"a": {"A0"},
+		"b": {"A0"},
+	}, crc32.ChecksumIEEE)
+	assert.Error(t, err)
+	_, err = maglev_hash.NewZoneAwareMaglev(1009, map[string][]string{"c": {}}, crc32.ChecksumIEEE)
+	assert.Error(t, err)
+
+	z, err := maglev_hash.NewZoneAwareMaglev(1009, zoneNodes, crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+	zone, ok := z.Zone("A0")
+	assert.True(t, ok)
+	assert.Equal(t, "a", zone)
+	_, ok = z.Zone("C0")
+	assert.False(t, ok)
+
+	global, err := maglev_hash.NewMaglevWithTableSize(1009, append(zoneNodes["a"], zoneNodes["b"]...), crc32.ChecksumIEEE)
+	assert.NoError(t, err)
+
+	// keys prefer the caller's zone and spread over nodes in the zone.
+	keySpaceCnt := 100_000
+	load := make(map[string]int)
+	for i := 0; i < keySpaceCnt; i++ {
+		key := []byte(strconv.Itoa(i))
+		node, ok := z.Node(key, "a")
+		assert.True(t, ok)
+		load[node]++
+
+		// an empty or unknown zone falls back to the cross-zone table.
+		node, ok = z.Node(key, "c")
+		assert.True(t, ok)
+		assert.Equal(t, global.Node(key), node)
+		node, ok = z.Node(key, "unknown")
+		assert.True(t, ok)
+		assert.Equal(t, global.Node(key), node)
+	}
+	assert.Len(t, load, 4)
+	for _, node := range zoneNodes["a"] {
+		assert.InDelta(t, keySpaceCnt/4, load[node], float64(keySpaceCnt)/4*0.05)
+	}
+
+	// a zone without healthy nodes falls back to healthy nodes of other zones.
+	assert.Error(t, z.MarkDown("C0"))
+	for _, node := range zoneNodes["a"] {
+		assert.NoError(t, z.MarkDown(node))
+	}
+	for i := 0; i < 1000; i++ {
+		node, ok := z.Node([]byte(strconv.Itoa(i)), "a")
+		assert.True(t, ok)
+		zone, _ := z.Zone(node)
+		assert.Equal(t, "b", zone)
+	}
+
+	// the zone is preferred again once a node is up.
+	assert.NoError(t, z.MarkUp("A2"))
+	node, ok := z.Node([]byte("key"), "a")
+	assert.True(t, ok)
+	assert.Equal(t, "A2", node)
+
+	for _, node := range zoneNodes["b"] {
+		assert.NoError(t, z.MarkDown(node))
+	}
+	assert.NoError(t, z.MarkDown("A2"))
+	_, ok = z.Node([]byte("key"), "a")
+	assert.False(t, ok)
+}
@@ -7,7 +7,7 @@ import (
 	"log"
 	"math/rand"
 
-	"github.com/pengubco/ads/prioritymap"
+	"github.com/pengubco/algorithms/priority_map"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -19,9 +19,7 @@ func main() {
 	})
 
 	// Redis sorted set uses string as key and int as value.
-	hs := prioritymap.NewPriorityMap[string, float64](func(v1, v2 float64) int {
-		return int(v1 - v2)
-	})
+	hs := priority_map.NewOrderedPriorityMap[string, float64]()
 
 	err := compareHeapSeatWithRedis(rdb, "ss", hs, 1_000_000)
 	if err != nil {
@@ -31,7 +29,7 @@ func main() {
 
 // Carry out n operations on PriorityMap and Redis. After each operation, get the Top() from PriorityMap
 // and compare it with the minimum value in Redis SortedSet.
-func compareHeapSeatWithRedis(rdb *redis.Client, sortedSetName string, hs *prioritymap.PriorityMap[string, float64], n int) error {
+func compareHeapSeatWithRedis(rdb *redis.Client, sortedSetName string, hs *priority_map.PriorityMap[string, float64], n int) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
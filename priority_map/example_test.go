@@ -0,0 +1,107 @@
+package priority_map_test
+
+import (
+	"cmp"
+	"fmt"
+	"time"
+
+	"github.com/pengubco/algorithms/priority_map"
+)
+
+func ExampleNewOrderedPriorityMap() {
+	pm := priority_map.NewOrderedPriorityMap[int, int]()
+	pm.Set(1, 10)
+	pm.Set(2, 10)
+	pm.Set(3, 30)
+	fmt.Printf("size: %d\n", pm.Size())
+	if v, ok := pm.Get(1); ok {
+		fmt.Printf("key: 1, value: %d\n", v)
+	}
+	// key 1 or key 2, whichever is on the top of the heap.
+	if _, v, ok := pm.Top(); ok {
+		fmt.Printf("top value: %d\n", v)
+	}
+	// Output:
+	// size: 3
+	// key: 1, value: 10
+	// top value: 10
+}
+
+type Job struct {
+	id         int
+	expiration time.Time
+	name       string
+}
+
+func ExampleNewPriorityMapWithCompare() {
+	pm := priority_map.NewPriorityMapWithCompare[int, *Job](func(v1, v2 *Job) int {
+		return v1.expiration.Compare(v2.expiration)
+	})
+	now, err := time.Parse("2006-01-02", "2022-12-30")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	jobs := []Job{
+		{1, now, "job 1"},
+		{2, now.Add(-1 * time.Minute), "job 2"},
+		{3, now.Add(-2 * time.Minute), "job 3"},
+	}
+	for i := range jobs {
+		pm.Set(jobs[i].id, &jobs[i])
+	}
+	id, job, _ := pm.Top()
+	fmt.Printf("job with the earliest expiration. id: %d, name: %s\n", id, job.name)
+	job, _ = pm.Get(2)
+	fmt.Printf("job 2's expiration is %v\n", job.expiration)
+	fmt.Println("taking jobs one by one, in the order of expiration time")
+	for pm.Size() > 0 {
+		id, job, _ := pm.Pop()
+		fmt.Printf("id: %d, name: %s, expiration: %v\n", id, job.name, job.expiration)
+	}
+	// Output:
+	// job with the earliest expiration. id: 3, name: job 3
+	// job 2's expiration is 2022-12-29 23:59:00 +0000 UTC
+	// taking jobs one by one, in the order of expiration time
+	// id: 3, name: job 3, expiration: 2022-12-29 23:58:00 +0000 UTC
+	// id: 2, name: job 2, expiration: 2022-12-29 23:59:00 +0000 UTC
+	// id: 1, name: job 1, expiration: 2022-12-30 00:00:00 +0000 UTC
+}
+
+func ExampleNewPriorityMapWithCompare_maxHeap() {
+	// Reverse the comparison to pop the largest value first.
+	pm := priority_map.NewPriorityMapWithCompare[string, int](func(v1, v2 int) int {
+		return cmp.Compare(v2, v1)
+	})
+	pm.Set("a", 1)
+	pm.Set("b", 3)
+	pm.Set("c", 2)
+	for pm.Size() > 0 {
+		k, v, _ := pm.Pop()
+		fmt.Println(k, v)
+	}
+	// Output:
+	// b 3
+	// c 2
+	// a 1
+}
+
+func ExamplePriorityMap_Ascend() {
+	pm := priority_map.NewOrderedPriorityMap[string, int]()
+	pm.Set("a", 3)
+	pm.Set("b", 1)
+	pm.Set("c", 2)
+	pm.Set("d", 4)
+	for k, v := range pm.Ascend() {
+		if v > 3 {
+			break
+		}
+		fmt.Println(k, v)
+	}
+	fmt.Println("size:", pm.Size())
+	// Output:
+	// b 1
+	// c 2
+	// a 3
+	// size: 4
+}
@@ -4,17 +4,31 @@
 //
 // 1. Get(K) V
 // 2. Set(K, V)
-// 3. Delete(K)
+// 3. Delete(K) (V, bool)
 // 4. Top() (K, V, bool)
 // 5. Pop() (K, V, bool)
 // 6. Size() int
+// 7. TopN(n) []Element[K, V]
+// 8. Ascend() iter.Seq2[K, V]
+// 9. All() iter.Seq2[K, V]
+// 10. Update(K, func(V) V) V
+// 11. GetOrSet(K, V) (V, bool)
+// 12. TopMax() (K, V, bool)
+// 13. PopMax() (K, V, bool)
+//
+// Together with the Increment function, they cover the common commands of a
+// Redis sorted set: ZADD (Set), ZSCORE (Get), ZREM (Delete), ZINCRBY (Increment),
+// ZPOPMIN (Pop), ZPOPMAX (PopMax), and ZRANGE (TopN).
 //
 // Usage
 //
-//	pm, _ := NewPriorityMap[int, string](func (v1, v2 string) bool {
+//	pm := NewPriorityMap[int, string](func (v1, v2 string) bool {
 //			return v1 < v2
 //	})
 //
+// or equivalently, NewPriorityMapWithCompare[int, string](strings.Compare), or
+// NewOrderedPriorityMap[int, string]().
+//
 // pm.Set(1, "a")
 // pm.Get(1) // returns "a"
 // pm.Set(2, "b")
@@ -24,11 +38,17 @@
 // pm.Delete(1)
 // pm.Size() // returns 2
 //
+// PriorityMap is not safe for concurrent use, see SyncPriorityMap.
+//
 // See more usage example in the priority_map_test.go.
 package priority_map
 
 import (
+	"cmp"
 	"container/heap"
+	"iter"
+
+	"github.com/pengubco/algorithms/priority_queue"
 )
 
 // PriorityMap keeps key-value pairs in a hash map and provides access to the pair of
@@ -37,6 +57,11 @@ type PriorityMap[K comparable, V any] struct {
 	// heap
 	h heap.Interface
 
+	// maxH is a max heap over the same elements, for TopMax and PopMax. It is
+	// nil until either is called, so that maps only accessed in min order do not
+	// pay for keeping it.
+	maxH *heapStruct[K, V]
+
 	// hashmap
 	m map[K]*Element[K, V]
 
@@ -54,6 +79,21 @@ func NewPriorityMap[K comparable, V any](less func(v1, v2 V) bool) *PriorityMap[
 	return &hs
 }
 
+// NewPriorityMapWithCompare returns a PriorityMap where values are ordered by the
+// given three-way compare function, which returns a negative number when v1 < v2,
+// zero when v1 == v2, and a positive number when v1 > v2, like cmp.Compare.
+func NewPriorityMapWithCompare[K comparable, V any](compare func(v1, v2 V) int) *PriorityMap[K, V] {
+	return NewPriorityMap[K, V](func(v1, v2 V) bool {
+		return compare(v1, v2) < 0
+	})
+}
+
+// NewOrderedPriorityMap returns a PriorityMap where values are ordered by the
+// natural order of V, as defined by cmp.Less.
+func NewOrderedPriorityMap[K comparable, V cmp.Ordered]() *PriorityMap[K, V] {
+	return NewPriorityMap[K, V](cmp.Less[V])
+}
+
 // Set inserts a k-v pair if the key does not exist. Otherwise, Set updates the value.
 func (pm *PriorityMap[K, V]) Set(k K, v V) {
 	existingElement, ok := pm.m[k]
@@ -63,11 +103,17 @@ func (pm *PriorityMap[K, V]) Set(k K, v V) {
 			Value: v,
 		}
 		heap.Push(pm.h, &e)
+		if pm.maxH != nil {
+			heap.Push(pm.maxH, &e)
+		}
 		pm.m[k] = &e
 		return
 	}
 	existingElement.Value = v
-	heap.Fix(pm.h, existingElement.index)
+	heap.Fix(pm.h, existingElement.index[minHeap])
+	if pm.maxH != nil {
+		heap.Fix(pm.maxH, existingElement.index[maxHeap])
+	}
 }
 
 // Get returns the value associated with the key
@@ -79,21 +125,34 @@ func (pm *PriorityMap[K, V]) Get(k K) (V, bool) {
 	return e.Value, true
 }
 
-// Delete deletes the key-value pair of the key.
-func (pm *PriorityMap[K, V]) Delete(key K) {
+// Delete deletes the key-value pair of the key. It returns the deleted value and
+// true, or false if the key does not exist.
+func (pm *PriorityMap[K, V]) Delete(key K) (V, bool) {
 	item, ok := pm.m[key]
 	if !ok {
-		return
+		return pm.emptyV, false
 	}
-	delete(pm.m, key)
-	index := item.index
-	if index == pm.h.Len()-1 {
-		pm.h.Pop()
-		return
+	pm.remove(item)
+	return item.Value, true
+}
+
+// Update sets the value of the key to fn(v), where v is the current value, or
+// the zero value of V if the key does not exist. It returns the new value.
+func (pm *PriorityMap[K, V]) Update(k K, fn func(v V) V) V {
+	v, _ := pm.Get(k)
+	v = fn(v)
+	pm.Set(k, v)
+	return v
+}
+
+// GetOrSet returns the existing value of the key and true if the key exists.
+// Otherwise, it sets the key to the given value, and returns the value and false.
+func (pm *PriorityMap[K, V]) GetOrSet(k K, v V) (V, bool) {
+	if existing, ok := pm.Get(k); ok {
+		return existing, true
 	}
-	pm.h.Swap(index, pm.h.Len()-1)
-	pm.h.Pop()
-	heap.Fix(pm.h, index)
+	pm.Set(k, v)
+	return v, false
 }
 
 // Top returns the key-value pair of the smallest value. It returns false
@@ -117,6 +176,35 @@ func (pm *PriorityMap[K, V]) Pop() (K, V, bool) {
 		return pm.emptyK, pm.emptyV, false
 	}
 	e := heap.Pop(pm.h).(*Element[K, V])
+	if pm.maxH != nil {
+		heap.Remove(pm.maxH, e.index[maxHeap])
+	}
+	delete(pm.m, e.Key)
+	return e.Key, e.Value, true
+}
+
+// TopMax returns the key-value pair of the largest value. It returns false if
+// the map is empty.
+// The first call of TopMax or PopMax builds a max heap over all pairs in O(n)
+// time. From then on, TopMax takes O(1) time, and other operations keep both
+// heaps, which roughly doubles their cost.
+func (pm *PriorityMap[K, V]) TopMax() (K, V, bool) {
+	if pm.h.Len() == 0 {
+		return pm.emptyK, pm.emptyV, false
+	}
+	e := pm.maxHeap().e[0]
+	return e.Key, e.Value, true
+}
+
+// PopMax removes and returns the key-value pair of the largest value. It returns
+// false if the map is empty. It takes O(log(n)) time, besides building the max
+// heap on the first call, see TopMax.
+func (pm *PriorityMap[K, V]) PopMax() (K, V, bool) {
+	if pm.h.Len() == 0 {
+		return pm.emptyK, pm.emptyV, false
+	}
+	e := heap.Pop(pm.maxHeap()).(*Element[K, V])
+	heap.Remove(pm.h, e.index[minHeap])
 	delete(pm.m, e.Key)
 	return e.Key, e.Value, true
 }
@@ -126,25 +214,137 @@ func (pm *PriorityMap[K, V]) Size() int {
 	return pm.h.Len()
 }
 
+// TopN returns up to n key-value pairs of the smallest values, in ascending
+// order of values. Unlike Pop, TopN does not modify the map. It takes
+// O(n*log(n)) time regardless of the size of the map.
+func (pm *PriorityMap[K, V]) TopN(n int) []Element[K, V] {
+	result := make([]Element[K, V], 0, min(max(n, 0), pm.Size()))
+	if n <= 0 {
+		return result
+	}
+	for k, v := range pm.Ascend() {
+		result = append(result, Element[K, V]{Key: k, Value: v})
+		if len(result) == n {
+			break
+		}
+	}
+	return result
+}
+
+// Ascend returns an iterator over key-value pairs in ascending order of values.
+// Pairs of equal values are yielded in unspecified order. Ascend does not modify
+// the map, and the map must not be modified during the iteration. Yielding the
+// first k pairs takes O(k*log(k)) time.
+func (pm *PriorityMap[K, V]) Ascend() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		h := pm.h.(*heapStruct[K, V])
+		if len(h.e) == 0 {
+			return
+		}
+		// frontier keeps the indexes of heap elements whose parents have been
+		// yielded. The smallest of them is the next to yield.
+		frontier, _ := priority_queue.NewPriorityQueue[int](func(i, j int) bool {
+			return h.less(h.e[i].Value, h.e[j].Value)
+		})
+		frontier.Push(0)
+		for frontier.Size() > 0 {
+			i, _ := frontier.Pop()
+			if !yield(h.e[i].Key, h.e[i].Value) {
+				return
+			}
+			for _, child := range []int{2*i + 1, 2*i + 2} {
+				if child < len(h.e) {
+					frontier.Push(child)
+				}
+			}
+		}
+	}
+}
+
+// All returns an iterator over all key-value pairs in unspecified order. The
+// map must not be modified during the iteration.
+func (pm *PriorityMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for k, e := range pm.m {
+			if !yield(k, e.Value) {
+				return
+			}
+		}
+	}
+}
+
 // Map returns the underlying map. It is here to provide an efficient way of
 // iterating over all key-value pairs.
 func (pm *PriorityMap[K, V]) Map() map[K]*Element[K, V] {
 	return pm.m
 }
 
+// remove removes the element from the hash map and the heaps.
+func (pm *PriorityMap[K, V]) remove(e *Element[K, V]) {
+	delete(pm.m, e.Key)
+	heap.Remove(pm.h, e.index[minHeap])
+	if pm.maxH != nil {
+		heap.Remove(pm.maxH, e.index[maxHeap])
+	}
+}
+
+// maxHeap returns the max heap, building it from the min heap if it does not
+// exist.
+func (pm *PriorityMap[K, V]) maxHeap() *heapStruct[K, V] {
+	if pm.maxH == nil {
+		h := pm.h.(*heapStruct[K, V])
+		pm.maxH = newHeapStruct[K, V](func(v1, v2 V) bool {
+			return h.less(v2, v1)
+		})
+		pm.maxH.which = maxHeap
+		pm.maxH.e = make([]*Element[K, V], len(h.e))
+		for i, e := range h.e {
+			pm.maxH.e[i] = e
+			e.index[maxHeap] = i
+		}
+		heap.Init(pm.maxH)
+	}
+	return pm.maxH
+}
+
+// Number is the constraint of values that can be incremented.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// Increment adds delta to the value of the key, like ZINCRBY in Redis. A key
+// that does not exist starts from 0. It returns the new value.
+func Increment[K comparable, V Number](pm *PriorityMap[K, V], k K, delta V) V {
+	return pm.Update(k, func(v V) V {
+		return v + delta
+	})
+}
+
 // Element is the unit of data stored in hash map and the heap.
 type Element[K comparable, V any] struct {
 	Key   K
 	Value V
 
-	// The array index of the element in the heap.
-	index int
+	// The array indexes of the element in the min heap and the max heap.
+	index [2]int
 }
 
+// Indexes of heaps in Element.index.
+const (
+	minHeap = 0
+	maxHeap = 1
+)
+
 // heapStruct implements the heap.Interface.
 type heapStruct[K comparable, V any] struct {
 	e    []*Element[K, V]
 	less func(v1, v2 V) bool
+
+	// which is minHeap or maxHeap, telling which of Element.index the heap
+	// keeps array indexes in.
+	which int
 }
 
 func newHeapStruct[K comparable, V any](less func(v1, v2 V) bool) *heapStruct[K, V] {
@@ -163,14 +363,14 @@ func (h *heapStruct[K, V]) Less(i, j int) bool {
 
 func (h *heapStruct[K, V]) Swap(i, j int) {
 	h.e[i], h.e[j] = h.e[j], h.e[i]
-	h.e[i].index = i
-	h.e[j].index = j
+	h.e[i].index[h.which] = i
+	h.e[j].index[h.which] = j
 }
 
 func (h *heapStruct[K, V]) Push(x any) {
 	n := len(h.e)
 	item := x.(*Element[K, V])
-	item.index = n
+	item.index[h.which] = n
 	h.e = append(h.e, item)
 }
 
@@ -178,8 +378,8 @@ func (h *heapStruct[K, V]) Pop() any {
 	old := h.e
 	n := len(old)
 	item := old[n-1]
-	old[n-1] = nil  // avoid memory leak
-	item.index = -1 // for safety
+	old[n-1] = nil           // avoid memory leak
+	item.index[h.which] = -1 // for safety
 	h.e = old[0 : n-1]
 	return item
 }
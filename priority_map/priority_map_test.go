@@ -9,6 +9,7 @@ import (
 	"slices"
 
 	"github.com/pengubco/algorithms/priority_map"
+	"github.com/samber/lo"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -120,6 +121,216 @@ func TestPriorityMap_CompositeV(t *testing.T) {
 	}
 }
 
+func TestPriorityMap_TopN(t *testing.T) {
+	assert := assert.New(t)
+	pm := priority_map.NewOrderedPriorityMap[int, int]()
+	assert.Empty(pm.TopN(3))
+
+	n := 1000
+	for k, v := range shuffledIndexes(n) {
+		pm.Set(k, v)
+	}
+	assert.Empty(pm.TopN(0))
+	assert.Empty(pm.TopN(-1))
+
+	top := pm.TopN(10)
+	assert.Len(top, 10)
+	for i, e := range top {
+		assert.Equal(i, e.Value)
+		v, _ := pm.Get(e.Key)
+		assert.Equal(v, e.Value)
+	}
+	assert.Len(pm.TopN(n+1), n)
+
+	// TopN does not modify the map.
+	assert.Equal(n, pm.Size())
+	for i := 0; i < n; i++ {
+		_, v, ok := pm.Pop()
+		assert.True(ok)
+		assert.Equal(i, v)
+	}
+}
+
+func TestPriorityMap_Ascend(t *testing.T) {
+	assert := assert.New(t)
+	pm := priority_map.NewOrderedPriorityMap[string, int]()
+	for range pm.Ascend() {
+		assert.Fail("empty map yields nothing")
+	}
+
+	n := 1000
+	for k, v := range shuffledIndexes(n) {
+		pm.Set(fmt.Sprintf("k%d", k), v/2)
+	}
+	pm.Delete("k0")
+	pm.Set("k1", -1)
+
+	var values []int
+	for k, v := range pm.Ascend() {
+		got, ok := pm.Get(k)
+		assert.True(ok)
+		assert.Equal(got, v)
+		values = append(values, v)
+	}
+	assert.Len(values, n-1)
+	assert.True(slices.IsSorted(values))
+	assert.Equal(-1, values[0])
+
+	// stop early
+	cnt := 0
+	for range pm.Ascend() {
+		cnt++
+		if cnt == 5 {
+			break
+		}
+	}
+	assert.Equal(5, cnt)
+	assert.Equal(n-1, pm.Size())
+}
+
+func TestPriorityMap_All(t *testing.T) {
+	assert := assert.New(t)
+	pm := priority_map.NewOrderedPriorityMap[int, int]()
+	for i := 0; i < 100; i++ {
+		pm.Set(i, -i)
+	}
+	seen := make(map[int]int)
+	for k, v := range pm.All() {
+		seen[k] = v
+	}
+	assert.Len(seen, 100)
+	for k, v := range seen {
+		assert.Equal(-k, v)
+	}
+}
+
+func TestPriorityMap_Delete(t *testing.T) {
+	assert := assert.New(t)
+	pm := priority_map.NewOrderedPriorityMap[string, int]()
+	pm.Set("a", 1)
+	pm.Set("b", 2)
+	_, ok := pm.Delete("c")
+	assert.False(ok)
+	v, ok := pm.Delete("a")
+	assert.True(ok)
+	assert.Equal(1, v)
+	_, ok = pm.Delete("a")
+	assert.False(ok)
+	assert.Equal(1, pm.Size())
+}
+
+func TestPriorityMap_UpdateAndIncrement(t *testing.T) {
+	assert := assert.New(t)
+	pm := priority_map.NewOrderedPriorityMap[string, float64]()
+	assert.Equal(2.5, priority_map.Increment(pm, "a", 2.5))
+	assert.Equal(1.5, priority_map.Increment(pm, "a", -1))
+	assert.Equal(3.0, priority_map.Increment(pm, "b", 3))
+	k, v, _ := pm.Top()
+	assert.Equal("a", k)
+	assert.Equal(1.5, v)
+
+	assert.Equal(6.0, pm.Update("a", func(v float64) float64 { return v * 4 }))
+	k, _, _ = pm.Top()
+	assert.Equal("b", k)
+	assert.Equal(10.0, pm.Update("c", func(v float64) float64 { return v + 10 }))
+	assert.Equal(3, pm.Size())
+}
+
+func TestPriorityMap_GetOrSet(t *testing.T) {
+	assert := assert.New(t)
+	pm := priority_map.NewOrderedPriorityMap[string, int]()
+	v, ok := pm.GetOrSet("a", 1)
+	assert.False(ok)
+	assert.Equal(1, v)
+	v, ok = pm.GetOrSet("a", 2)
+	assert.True(ok)
+	assert.Equal(1, v)
+	v, _ = pm.Get("a")
+	assert.Equal(1, v)
+}
+
+func TestPriorityMap_Max(t *testing.T) {
+	assert := assert.New(t)
+	pm := priority_map.NewOrderedPriorityMap[int, int]()
+	_, _, ok := pm.TopMax()
+	assert.False(ok)
+	_, _, ok = pm.PopMax()
+	assert.False(ok)
+
+	n := 1000
+	for k, v := range shuffledIndexes(n) {
+		pm.Set(k, v)
+	}
+	k, v, ok := pm.TopMax()
+	assert.True(ok)
+	assert.Equal(n-1, v)
+	got, _ := pm.Get(k)
+	assert.Equal(n-1, got)
+
+	// Pop from both ends.
+	for i := 0; i < n/2; i++ {
+		k, v, ok = pm.PopMax()
+		assert.True(ok)
+		assert.Equal(n-1-i, v)
+		_, ok = pm.Get(k)
+		assert.False(ok)
+
+		_, v, ok = pm.Pop()
+		assert.True(ok)
+		assert.Equal(i, v)
+	}
+	assert.Equal(0, pm.Size())
+}
+
+// TestPriorityMap_MinAndMax mixes min and max operations with updates and
+// deletions, so that both heaps are kept after the max heap is built.
+func TestPriorityMap_MinAndMax(t *testing.T) {
+	assert := assert.New(t)
+	r := rand.New(rand.NewSource(1))
+	pm := priority_map.NewOrderedPriorityMap[int, int]()
+	model := make(map[int]int)
+	for i := 0; i < 5000; i++ {
+		k := r.Intn(300)
+		switch op := r.Intn(6); op {
+		case 0, 1:
+			v := r.Intn(1000)
+			pm.Set(k, v)
+			model[k] = v
+		case 2:
+			_, ok := pm.Delete(k)
+			_, exists := model[k]
+			assert.Equal(exists, ok)
+			delete(model, k)
+		case 3, 4:
+			pop := pm.Pop
+			if op == 4 {
+				pop = pm.PopMax
+			}
+			k, v, ok := pop()
+			assert.Equal(len(model) > 0, ok)
+			if !ok {
+				continue
+			}
+			assert.Equal(model[k], v)
+			for _, other := range model {
+				if op == 3 {
+					assert.LessOrEqual(v, other)
+				} else {
+					assert.GreaterOrEqual(v, other)
+				}
+			}
+			delete(model, k)
+		case 5:
+			_, v, ok := pm.TopMax()
+			assert.Equal(len(model) > 0, ok)
+			if ok {
+				assert.Equal(lo.Max(lo.Values(model)), v)
+			}
+		}
+		assert.Equal(len(model), pm.Size())
+	}
+}
+
 // Add 1M key-value pairs in random values.
 func BenchmarkPriorityMap_Add_1M(b *testing.B) {
 	n := 1_000_000
@@ -200,6 +411,26 @@ func BenchmarkPriorityMap_Pop_1M(b *testing.B) {
 	}
 }
 
+// PopMax 1M key-value pairs in random order
+func BenchmarkPriorityMap_PopMax_1M(b *testing.B) {
+	n := 1_000_000
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+		pm := priority_map.NewPriorityMap[int, int](func(v1, v2 int) bool {
+			return v1 < v2
+		})
+		for j := 0; j < n; j++ {
+			pm.Set(j, r.Int())
+		}
+
+		b.StartTimer()
+		for j := 0; j < n; j++ {
+			pm.PopMax()
+		}
+	}
+}
+
 // returns an array [0, n) in random order
 func shuffledIndexes(n int) []int {
 	indexes := make([]int, n)
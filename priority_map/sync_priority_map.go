@@ -0,0 +1,169 @@
+package priority_map
+
+import (
+	"cmp"
+	"context"
+	"sync"
+)
+
+// SyncPriorityMap is a PriorityMap that is safe for concurrent use by multiple
+// goroutines. All methods are guarded by a mutex. PopWait blocks until the map
+// is not empty, so consumers do not need to poll Top.
+type SyncPriorityMap[K comparable, V any] struct {
+	mu sync.Mutex
+	pm *PriorityMap[K, V]
+
+	// notEmpty is closed when a pair is set, to wake up goroutines blocked in
+	// PopWait. It is nil if no goroutine is waiting.
+	notEmpty chan struct{}
+}
+
+// NewSyncPriorityMap returns a SyncPriorityMap where values are ordered by the given less function.
+func NewSyncPriorityMap[K comparable, V any](less func(v1, v2 V) bool) *SyncPriorityMap[K, V] {
+	return &SyncPriorityMap[K, V]{
+		pm: NewPriorityMap[K, V](less),
+	}
+}
+
+// NewSyncPriorityMapWithCompare returns a SyncPriorityMap where values are
+// ordered by the given three-way compare function, like cmp.Compare.
+func NewSyncPriorityMapWithCompare[K comparable, V any](compare func(v1, v2 V) int) *SyncPriorityMap[K, V] {
+	return &SyncPriorityMap[K, V]{
+		pm: NewPriorityMapWithCompare[K, V](compare),
+	}
+}
+
+// NewOrderedSyncPriorityMap returns a SyncPriorityMap where values are ordered
+// by the natural order of V, as defined by cmp.Less.
+func NewOrderedSyncPriorityMap[K comparable, V cmp.Ordered]() *SyncPriorityMap[K, V] {
+	return &SyncPriorityMap[K, V]{
+		pm: NewOrderedPriorityMap[K, V](),
+	}
+}
+
+// Set inserts a k-v pair if the key does not exist. Otherwise, Set updates the value.
+func (sm *SyncPriorityMap[K, V]) Set(k K, v V) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.pm.Set(k, v)
+	sm.signal()
+}
+
+// Get returns the value associated with the key.
+func (sm *SyncPriorityMap[K, V]) Get(k K) (V, bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.pm.Get(k)
+}
+
+// Delete deletes the key-value pair of the key. It returns the deleted value and
+// true, or false if the key does not exist.
+func (sm *SyncPriorityMap[K, V]) Delete(k K) (V, bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.pm.Delete(k)
+}
+
+// Update sets the value of the key to fn(v), where v is the current value, or
+// the zero value of V if the key does not exist. It returns the new value.
+// fn is called with the lock held, so it must not call methods of the map.
+func (sm *SyncPriorityMap[K, V]) Update(k K, fn func(v V) V) V {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	v := sm.pm.Update(k, fn)
+	sm.signal()
+	return v
+}
+
+// GetOrSet returns the existing value of the key and true if the key exists.
+// Otherwise, it sets the key to the given value, and returns the value and false.
+func (sm *SyncPriorityMap[K, V]) GetOrSet(k K, v V) (V, bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	v, ok := sm.pm.GetOrSet(k, v)
+	if !ok {
+		sm.signal()
+	}
+	return v, ok
+}
+
+// Top returns the key-value pair of the smallest value. It returns false
+// if the map is empty.
+func (sm *SyncPriorityMap[K, V]) Top() (K, V, bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.pm.Top()
+}
+
+// Pop removes and returns the key-value pair of the smallest value. It returns
+// false if the map is empty.
+func (sm *SyncPriorityMap[K, V]) Pop() (K, V, bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.pm.Pop()
+}
+
+// PopWait removes and returns the key-value pair of the smallest value. If the
+// map is empty, PopWait blocks until a pair is set or ctx is done, in which case
+// it returns ctx.Err(). When multiple goroutines are waiting, each pair is
+// returned to only one of them.
+func (sm *SyncPriorityMap[K, V]) PopWait(ctx context.Context) (K, V, error) {
+	for {
+		sm.mu.Lock()
+		if k, v, ok := sm.pm.Pop(); ok {
+			sm.mu.Unlock()
+			return k, v, nil
+		}
+		if sm.notEmpty == nil {
+			sm.notEmpty = make(chan struct{})
+		}
+		notEmpty := sm.notEmpty
+		sm.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return sm.pm.emptyK, sm.pm.emptyV, ctx.Err()
+		case <-notEmpty:
+		}
+	}
+}
+
+// TopMax returns the key-value pair of the largest value. It returns false if
+// the map is empty. See PriorityMap.TopMax for its cost.
+func (sm *SyncPriorityMap[K, V]) TopMax() (K, V, bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.pm.TopMax()
+}
+
+// PopMax removes and returns the key-value pair of the largest value. It returns
+// false if the map is empty. It takes O(log(n)) time, see PriorityMap.PopMax.
+func (sm *SyncPriorityMap[K, V]) PopMax() (K, V, bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.pm.PopMax()
+}
+
+// TopN returns up to n key-value pairs of the smallest values, in ascending
+// order of values, without modifying the map.
+func (sm *SyncPriorityMap[K, V]) TopN(n int) []Element[K, V] {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.pm.TopN(n)
+}
+
+// Size returns the number of key-value pairs.
+func (sm *SyncPriorityMap[K, V]) Size() int {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.pm.Size()
+}
+
+// signal wakes up goroutines blocked in PopWait. It must be called with the
+// lock held.
+func (sm *SyncPriorityMap[K, V]) signal() {
+	if sm.notEmpty != nil {
+		close(sm.notEmpty)
+		sm.notEmpty = nil
+	}
+}
@@ -0,0 +1,125 @@
+package priority_map_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pengubco/algorithms/priority_map"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncPriorityMap(t *testing.T) {
+	assert := assert.New(t)
+	sm := priority_map.NewOrderedSyncPriorityMap[string, int]()
+	sm.Set("a", 3)
+	sm.Set("b", 1)
+	v, ok := sm.GetOrSet("c", 2)
+	assert.False(ok)
+	assert.Equal(2, v)
+	assert.Equal(4, sm.Update("c", func(v int) int { return v * 2 }))
+	assert.Equal(3, sm.Size())
+
+	k, v, ok := sm.Top()
+	assert.True(ok)
+	assert.Equal("b", k)
+	assert.Equal(1, v)
+	k, _, _ = sm.TopMax()
+	assert.Equal("c", k)
+	assert.Equal([]priority_map.Element[string, int]{{Key: "b", Value: 1}, {Key: "a", Value: 3}}, sm.TopN(2))
+
+	v, ok = sm.Delete("a")
+	assert.True(ok)
+	assert.Equal(3, v)
+	k, _, _ = sm.PopMax()
+	assert.Equal("c", k)
+	k, _, _ = sm.Pop()
+	assert.Equal("b", k)
+	_, _, ok = sm.Pop()
+	assert.False(ok)
+}
+
+func TestSyncPriorityMap_PopWait(t *testing.T) {
+	assert := assert.New(t)
+	sm := priority_map.NewOrderedSyncPriorityMap[int, int]()
+
+	// PopWait returns immediately if the map is not empty.
+	sm.Set(1, 10)
+	k, v, err := sm.PopWait(context.Background())
+	assert.NoError(err)
+	assert.Equal(1, k)
+	assert.Equal(10, v)
+
+	// PopWait returns the error of ctx if nothing is set.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, _, err = sm.PopWait(ctx)
+	assert.ErrorIs(err, context.DeadlineExceeded)
+
+	// PopWait blocks until a pair is set.
+	started, done := make(chan struct{}), make(chan int, 1)
+	go func() {
+		close(started)
+		k, _, err := sm.PopWait(context.Background())
+		assert.NoError(err)
+		done <- k
+	}()
+	<-started
+	// give PopWait a chance to return early, which it must not do.
+	time.Sleep(10 * time.Millisecond)
+	select {
+	case k := <-done:
+		assert.Failf("PopWait returned before Set", "key %d", k)
+	default:
+	}
+	sm.Set(2, 20)
+	assert.Equal(2, <-done)
+	assert.Equal(0, sm.Size())
+}
+
+func TestSyncPriorityMap_ConcurrentProducersAndConsumers(t *testing.T) {
+	sm := priority_map.NewOrderedSyncPriorityMap[int, int]()
+	producerCnt, consumerCnt, n := 4, 4, 1000
+
+	var consumers sync.WaitGroup
+	popped := make(chan int, producerCnt*n)
+	ctx, cancel := context.WithCancel(context.Background())
+	for i := 0; i < consumerCnt; i++ {
+		consumers.Add(1)
+		go func() {
+			defer consumers.Done()
+			for {
+				k, _, err := sm.PopWait(ctx)
+				if err != nil {
+					return
+				}
+				popped <- k
+			}
+		}()
+	}
+
+	var producers sync.WaitGroup
+	for i := 0; i < producerCnt; i++ {
+		producers.Add(1)
+		go func(i int) {
+			defer producers.Done()
+			for j := 0; j < n; j++ {
+				sm.Set(i*n+j, j)
+			}
+		}(i)
+	}
+	producers.Wait()
+
+	// Every pair is popped exactly once.
+	seen := make(map[int]bool)
+	for len(seen) < producerCnt*n {
+		k := <-popped
+		assert.False(t, seen[k], "key %d popped twice", k)
+		seen[k] = true
+	}
+	cancel()
+	consumers.Wait()
+	assert.Equal(t, 0, sm.Size())
+	assert.Empty(t, popped)
+}
@@ -5,7 +5,9 @@
 // 4. Size() int.
 //
 // Usage
-// pq, err := NewPriorityQueue(func(v1, v2 int){return v1<v2})
+// pq, err := NewPriorityQueue(func(v1, v2 int) bool {return v1<v2})
+// or equivalently, NewPriorityQueueWithCompare(cmp.Compare[int]), or
+// NewOrderedPriorityQueue[int]().
 // pq.Push(10)
 // v, _ := pq.Top()
 // v, _ = pq.Pop()
@@ -20,6 +22,7 @@
 package priority_queue
 
 import (
+	"cmp"
 	"container/heap"
 	"errors"
 )
@@ -47,6 +50,26 @@ func NewPriorityQueue[V any](less func(v1, v2 V) bool) (*PriorityQueue[V], error
 	return pq, nil
 }
 
+// NewPriorityQueueWithCompare returns a priority queue where values are ordered
+// by the given three-way compare function, which returns a negative number when
+// v1 < v2, zero when v1 == v2, and a positive number when v1 > v2, like
+// cmp.Compare. Returns error when the compare function is nil.
+func NewPriorityQueueWithCompare[V any](compare func(v1, v2 V) int) (*PriorityQueue[V], error) {
+	if compare == nil {
+		return nil, errors.New("must provide the compare function")
+	}
+	return NewPriorityQueue[V](func(v1, v2 V) bool {
+		return compare(v1, v2) < 0
+	})
+}
+
+// NewOrderedPriorityQueue returns a priority queue where values are ordered by
+// the natural order of V, as defined by cmp.Less.
+func NewOrderedPriorityQueue[V cmp.Ordered]() *PriorityQueue[V] {
+	pq, _ := NewPriorityQueue[V](cmp.Less[V])
+	return pq
+}
+
 // Push inserts a value to the priority queue.
 func (pq *PriorityQueue[V]) Push(v V) {
 	e := heapElement[V]{
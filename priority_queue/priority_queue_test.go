@@ -1,6 +1,8 @@
 package priority_queue_test
 
 import (
+	"cmp"
+	"strings"
 	"testing"
 	"time"
 
@@ -67,3 +69,44 @@ func TestPriorityQueue_CustomizedType(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "job1", job.name)
 }
+
+func TestPriorityQueue_Compare(t *testing.T) {
+	_, err := priority_queue.NewPriorityQueueWithCompare[int](nil)
+	assert.Error(t, err)
+
+	pq, err := priority_queue.NewPriorityQueueWithCompare(strings.Compare)
+	assert.NoError(t, err)
+	for _, v := range []string{"c", "a", "b"} {
+		pq.Push(v)
+	}
+	for _, v := range []string{"a", "b", "c"} {
+		popped, err := pq.Pop()
+		assert.NoError(t, err)
+		assert.Equal(t, v, popped)
+	}
+
+	// max heap
+	pq2, err := priority_queue.NewPriorityQueueWithCompare(func(v1, v2 int) int {
+		return cmp.Compare(v2, v1)
+	})
+	assert.NoError(t, err)
+	pq2.Push(1)
+	pq2.Push(3)
+	pq2.Push(2)
+	v, err := pq2.Top()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, v)
+}
+
+func TestPriorityQueue_Ordered(t *testing.T) {
+	pq := priority_queue.NewOrderedPriorityQueue[float64]()
+	for _, v := range []float64{2.5, -1, 10} {
+		pq.Push(v)
+	}
+	for _, v := range []float64{-1, 2.5, 10} {
+		popped, err := pq.Pop()
+		assert.NoError(t, err)
+		assert.Equal(t, v, popped)
+	}
+	assert.Equal(t, 0, pq.Size())
+}
@@ -0,0 +1,93 @@
+// Package rendezvous_hash implements rendezvous hashing, a.k.a. highest random
+// weight (HRW) hashing, from the paper
+// [A Name-Based Mapping Scheme for Rendezvous](https://www.eecs.umich.edu/techreports/cse/96/CSE-TR-316-96.pdf)
+// For each key, every node gets a score from hashing the key and the node
+// together. The key is assigned to the node of the highest score.
+//  1. Load Balance. Each node gets 1/N of the keys in expectation.
+//  2. Minimal disruption. Adding or removing a node only moves keys to or from
+//     that node.
+//
+// A lookup takes O(N) time, so it is best suited for a small number of nodes.
+//
+// Example
+// rh, _ := NewRendezvousHash([]string{"B0", "B1"})
+// node := rh.Node([]byte("key1"))
+package rendezvous_hash
+
+import (
+	"fmt"
+	"hash/crc32"
+	"slices"
+	"sort"
+
+	"github.com/pengubco/algorithms/consistent_hash"
+	"github.com/samber/lo"
+)
+
+var _ consistent_hash.ConsistentHash = (*RendezvousHash)(nil)
+
+// RendezvousHash assigns keys to nodes of the highest score.
+// 1. Nodes are identified by string.
+// 2. The default hash function hashing both keys and nodes is CRC32.
+type RendezvousHash struct {
+	nodes []string
+
+	// nodeHashes[i] is the hash of node[i].
+	nodeHashes []uint32
+
+	keyHashFn consistent_hash.KeyHashFnType
+}
+
+// NewRendezvousHash creates a rendezvous hash for the given nodes, using the
+// CRC32 hash function.
+func NewRendezvousHash(nodes []string) (*RendezvousHash, error) {
+	return NewRendezvousHashWithKeyHash(nodes, crc32.ChecksumIEEE)
+}
+
+// NewRendezvousHashWithKeyHash creates a rendezvous hash for the given nodes.
+// The hash function hashes both keys and nodes. In order to break ties of score
+// stably, the given list of nodes are sorted and deduplicated.
+func NewRendezvousHashWithKeyHash(nodes []string, keyHashFn consistent_hash.KeyHashFnType) (*RendezvousHash, error) {
+	nodes = lo.Uniq(nodes)
+	sort.Strings(nodes)
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no node")
+	}
+	return &RendezvousHash{
+		nodes: nodes,
+		nodeHashes: lo.Map(nodes, func(node string, _ int) uint32 {
+			return keyHashFn([]byte(node))
+		}),
+		keyHashFn: keyHashFn,
+	}, nil
+}
+
+// Node returns the node of the highest score for the given key.
+func (r *RendezvousHash) Node(key []byte) string {
+	keyHash := uint64(r.keyHashFn(key))
+	result, maxScore := 0, uint64(0)
+	for i, nodeHash := range r.nodeHashes {
+		if s := score(keyHash, uint64(nodeHash)); s > maxScore {
+			result, maxScore = i, s
+		}
+	}
+	return r.nodes[result]
+}
+
+// Nodes returns the sorted nodes.
+func (r *RendezvousHash) Nodes() []string {
+	return slices.Clone(r.nodes)
+}
+
+// score combines the hash of a key and the hash of a node, and mixes the result
+// with the finalizer of SplitMix64 so that scores of different nodes are
+// independent.
+func score(keyHash, nodeHash uint64) uint64 {
+	h := keyHash<<32 | nodeHash
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return h
+}
@@ -0,0 +1,47 @@
+package rendezvous_hash_test
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/pengubco/algorithms/rendezvous_hash"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewError(t *testing.T) {
+	_, err := rendezvous_hash.NewRendezvousHash(nil)
+	assert.Error(t, err)
+}
+
+func TestLoadBalanceAndDisruption(t *testing.T) {
+	keySpaceCnt := 100_000
+	nodes := lo.Times(10, func(i int) string {
+		return fmt.Sprintf("B%d", i)
+	})
+	rh, err := rendezvous_hash.NewRendezvousHash(nodes)
+	assert.NoError(t, err)
+	assert.Equal(t, nodes, rh.Nodes())
+
+	keyToNode1 := make(map[int]string)
+	load := make(map[string]int)
+	for i := 0; i < keySpaceCnt; i++ {
+		node := rh.Node([]byte(strconv.Itoa(i)))
+		keyToNode1[i] = node
+		load[node]++
+	}
+	assert.Len(t, load, 10)
+	for _, v := range load {
+		assert.InDelta(t, keySpaceCnt/10, v, float64(keySpaceCnt)/10*0.1)
+	}
+
+	// removing a node only moves keys of that node.
+	rh, err = rendezvous_hash.NewRendezvousHash(lo.Without(nodes, "B5"))
+	assert.NoError(t, err)
+	for i := 0; i < keySpaceCnt; i++ {
+		if keyToNode1[i] != "B5" {
+			assert.Equal(t, keyToNode1[i], rh.Node([]byte(strconv.Itoa(i))))
+		}
+	}
+}
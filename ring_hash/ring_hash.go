@@ -0,0 +1,156 @@
+// Package ring_hash implements consistent hashing on a hash ring with virtual
+// nodes, from the paper
+// [Consistent Hashing and Random Trees](https://www.cs.princeton.edu/courses/archive/fall09/cos518/papers/chash.pdf)
+// Each node is placed on a ring of 32-bit hashes at multiple points, called
+// virtual nodes. A key is assigned to the node of the first virtual node at or
+// after the key's hash, going clockwise.
+//  1. Load Balance. The more virtual nodes, the more even the load.
+//  2. Minimal disruption. Adding or removing a node only moves keys to or from
+//     that node.
+//
+// Example
+// rh, _ := NewRingHash([]string{"B0", "B1"})
+// node := rh.Node([]byte("key1"))
+package ring_hash
+
+import (
+	"fmt"
+	"hash/crc32"
+	"slices"
+	"sort"
+	"strconv"
+
+	"github.com/pengubco/algorithms/consistent_hash"
+	"github.com/samber/lo"
+)
+
+const (
+	// DefaultVirtualNodeCnt is the default number of virtual nodes per node.
+	DefaultVirtualNodeCnt = 100
+)
+
+var _ consistent_hash.ConsistentHash = (*RingHash)(nil)
+
+// RingHash assigns keys to nodes on a hash ring.
+// 1. Nodes are identified by string.
+// 2. The default hash function hashing keys is CRC32. Virtual nodes are placed
+// with a well-mixed 64-bit hash regardless of the key hash function.
+type RingHash struct {
+	nodes []string
+
+	// Virtual nodes sorted by hash. hashes[i] is the hash of the i-th virtual
+	// node and owners[i] is the index of its node.
+	hashes []uint32
+	owners []int
+
+	keyHashFn consistent_hash.KeyHashFnType
+}
+
+// NewRingHash creates a ring hash for the given nodes, using the default number
+// of virtual nodes and the CRC32 hash function.
+func NewRingHash(nodes []string) (*RingHash, error) {
+	return NewRingHashWithVirtualNodes(DefaultVirtualNodeCnt, nodes, crc32.ChecksumIEEE)
+}
+
+// NewRingHashWithVirtualNodes creates a ring hash where each node has
+// virtualNodeCnt virtual nodes. The i-th virtual node of node "B0" is placed at
+// the hash of "B0#i" by virtualNodeHash. keyHashFn only hashes keys. In order to
+// make the ring stable, the given list of nodes are sorted and deduplicated.
+func NewRingHashWithVirtualNodes(virtualNodeCnt int, nodes []string, keyHashFn consistent_hash.KeyHashFnType) (*RingHash, error) {
+	if virtualNodeCnt <= 0 {
+		return nil, fmt.Errorf("number of virtual nodes must be positive, %d", virtualNodeCnt)
+	}
+	nodes = lo.Uniq(nodes)
+	sort.Strings(nodes)
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no node")
+	}
+	type virtualNode struct {
+		hash  uint32
+		owner int
+	}
+	virtualNodes := make([]virtualNode, 0, len(nodes)*virtualNodeCnt)
+	for i, node := range nodes {
+		for j := 0; j < virtualNodeCnt; j++ {
+			hash := virtualNodeHash(node + "#" + strconv.Itoa(j))
+			virtualNodes = append(virtualNodes, virtualNode{hash: hash, owner: i})
+		}
+	}
+	// break ties of hash by node, so that the ring does not depend on the order of nodes.
+	sort.Slice(virtualNodes, func(i, j int) bool {
+		if virtualNodes[i].hash != virtualNodes[j].hash {
+			return virtualNodes[i].hash < virtualNodes[j].hash
+		}
+		return virtualNodes[i].owner < virtualNodes[j].owner
+	})
+	r := &RingHash{
+		nodes:     nodes,
+		hashes:    make([]uint32, len(virtualNodes)),
+		owners:    make([]int, len(virtualNodes)),
+		keyHashFn: keyHashFn,
+	}
+	for i, v := range virtualNodes {
+		r.hashes[i] = v.hash
+		r.owners[i] = v.owner
+	}
+	return r, nil
+}
+
+// Node returns the assigned node for the given key.
+func (r *RingHash) Node(key []byte) string {
+	hash := r.keyHashFn(key)
+	i := sort.Search(len(r.hashes), func(i int) bool {
+		return r.hashes[i] >= hash
+	})
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.nodes[r.owners[i]]
+}
+
+// Nodes returns the sorted nodes.
+func (r *RingHash) Nodes() []string {
+	return slices.Clone(r.nodes)
+}
+
+// ArcShares returns the share of the ring each node owns, i.e., the fraction of
+// 32-bit hashes assigned to the node. It is the expected share of keys of each
+// node if keys are hashed uniformly.
+func (r *RingHash) ArcShares() map[string]float64 {
+	shares := make(map[string]float64, len(r.nodes))
+	n := len(r.hashes)
+	for i := range r.hashes {
+		// the i-th virtual node owns hashes in (hashes[i-1], hashes[i]], and the
+		// first one also owns hashes after the last virtual node.
+		arc := uint64(r.hashes[i]) - uint64(r.hashes[(i+n-1)%n])
+		if i == 0 {
+			arc += 1 << 32
+		}
+		shares[r.nodes[r.owners[i]]] += float64(arc) / (1 << 32)
+	}
+	return shares
+}
+
+const (
+	fnvOffset64 uint64 = 14695981039346656037
+	fnvPrime64  uint64 = 1099511628211
+)
+
+// virtualNodeHash returns the position of a virtual node on the ring. It is the
+// 64-bit FNV-1a hash mixed with the finalizer of SplitMix64, truncated to the
+// high 32 bits. CRC32, the default key hash function, is linear, so virtual
+// nodes of the same node, whose names differ in a few bytes, would cluster on
+// the ring.
+func virtualNodeHash(s string) uint32 {
+	h := fnvOffset64
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= fnvPrime64
+	}
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return uint32(h >> 32)
+}
@@ -0,0 +1,81 @@
+package ring_hash_test
+
+import (
+	"fmt"
+	"hash/crc32"
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/pengubco/algorithms/ring_hash"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewError(t *testing.T) {
+	_, err := ring_hash.NewRingHash(nil)
+	assert.Error(t, err)
+
+	_, err = ring_hash.NewRingHashWithVirtualNodes(0, []string{"B0"}, crc32.ChecksumIEEE)
+	assert.Error(t, err)
+}
+
+func TestLoadBalanceAndDisruption(t *testing.T) {
+	keySpaceCnt := 100_000
+	nodes := lo.Times(10, func(i int) string {
+		return fmt.Sprintf("B%d", i)
+	})
+	rh, err := ring_hash.NewRingHash(nodes)
+	assert.NoError(t, err)
+	assert.Equal(t, nodes, rh.Nodes())
+
+	// the ring does not depend on the order of nodes.
+	rh2, err := ring_hash.NewRingHash(lo.Reverse(append([]string{}, nodes...)))
+	assert.NoError(t, err)
+
+	keyToNode1 := make(map[int]string)
+	load := make(map[string]int)
+	for i := 0; i < keySpaceCnt; i++ {
+		key := []byte(strconv.Itoa(i))
+		node := rh.Node(key)
+		assert.Equal(t, node, rh2.Node(key))
+		keyToNode1[i] = node
+		load[node]++
+	}
+	assert.Len(t, load, 10)
+	// With 100 virtual nodes per node, the share of a node deviates from 1/10
+	// by about 10% in standard deviation.
+	shares := rh.ArcShares()
+	assert.InDelta(t, 1.0, lo.Sum(lo.Values(shares)), 1e-9)
+	for node, v := range load {
+		assert.InDelta(t, keySpaceCnt/10, v, float64(keySpaceCnt)/10*0.25)
+		assert.InDelta(t, shares[node], float64(v)/float64(keySpaceCnt), 0.01)
+	}
+
+	// removing a node only moves keys of that node.
+	rh, err = ring_hash.NewRingHash(lo.Without(nodes, "B5"))
+	assert.NoError(t, err)
+	for i := 0; i < keySpaceCnt; i++ {
+		if keyToNode1[i] != "B5" {
+			assert.Equal(t, keyToNode1[i], rh.Node([]byte(strconv.Itoa(i))))
+		}
+	}
+}
+
+// TestVirtualNodeSpread checks that virtual nodes of a node spread over the
+// ring. Shares of nodes with v virtual nodes each have a coefficient of
+// variation of about 1/sqrt(v).
+func TestVirtualNodeSpread(t *testing.T) {
+	nodes := lo.Times(100, func(i int) string {
+		return fmt.Sprintf("B%d", i)
+	})
+	for _, virtualNodeCnt := range []int{100, 655} {
+		rh, err := ring_hash.NewRingHashWithVirtualNodes(virtualNodeCnt, nodes, crc32.ChecksumIEEE)
+		assert.NoError(t, err)
+		shares := lo.Values(rh.ArcShares())
+		mean := lo.Sum(shares) / float64(len(shares))
+		variance := lo.SumBy(shares, func(v float64) float64 { return (v - mean) * (v - mean) }) / float64(len(shares))
+		cv := math.Sqrt(variance) / mean
+		assert.Less(t, cv, 1.3/math.Sqrt(float64(virtualNodeCnt)), "%d virtual nodes", virtualNodeCnt)
+	}
+}
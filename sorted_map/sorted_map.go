@@ -0,0 +1,356 @@
+// Package sorted_map implements a key-value store where all key-value pairs are
+// sorted by value, like a Redis sorted set. Unlike PriorityMap, which only
+// orders the smallest value, SortedMap supports rank and range queries.
+// The value must be orderable (a < b) and the key must be comparable (a == b).
+// Pairs of equal values are ordered by the time their values are set.
+//
+//  1. Get(K) (V, bool)
+//  2. Set(K, V)
+//  3. Delete(K) (V, bool)
+//  4. Top() (K, V, bool)
+//  5. Pop() (K, V, bool)
+//  6. Size() int
+//  7. Rank(K) (int, bool)
+//  8. ByRank(int) (K, V, bool)
+//  9. RangeByRank(start, end int) []Element[K, V]
+//  10. RangeByValue(lo, hi V) []Element[K, V]
+//  11. Ascend() iter.Seq2[K, V]
+//
+// Pairs are kept in a skip list where each link records the number of pairs it
+// skips over, so that rank queries take O(log(n)) time.
+//
+// Usage
+//
+//	sm := NewOrderedSortedMap[string, int]()
+//	sm.Set("a", 30)
+//	sm.Set("b", 10)
+//	sm.Set("c", 20)
+//	sm.Rank("a") // returns (2, true)
+//	sm.ByRank(0) // returns ("b", 10, true)
+//	sm.RangeByValue(15, 30) // returns [{c 20} {a 30}]
+//
+// See more usage example in the sorted_map_test.go.
+package sorted_map
+
+import (
+	"cmp"
+	"iter"
+	"math/rand"
+)
+
+const (
+	// maxLevel is the max number of levels of the skip list, enough for 4^32 pairs.
+	maxLevel = 32
+
+	// p is the probability that a node at level i also appears at level i+1.
+	p = 0.25
+)
+
+// SortedMap keeps key-value pairs in a hash map and a skip list sorted by value.
+type SortedMap[K comparable, V any] struct {
+	less func(v1, v2 V) bool
+
+	// hashmap
+	m map[K]*node[K, V]
+
+	// head of the skip list. It does not hold a key-value pair.
+	head *node[K, V]
+
+	// number of levels in use.
+	level int
+
+	// seq is the sequence number of the next value set. It orders pairs of
+	// equal values.
+	seq uint64
+
+	emptyK K
+	emptyV V
+}
+
+// Element is a key-value pair returned by range queries.
+type Element[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+// node is a key-value pair in the skip list.
+type node[K comparable, V any] struct {
+	key   K
+	value V
+	seq   uint64
+
+	// levels[i] is the link to the next node at level i.
+	levels []link[K, V]
+}
+
+// link points to the next node at a level.
+type link[K comparable, V any] struct {
+	next *node[K, V]
+
+	// span is the number of nodes from the current node to the next node, e.g.,
+	// 1 means the next node is adjacent. If next is nil, span counts the nodes
+	// to the end of the list.
+	span int
+}
+
+// NewSortedMap returns a SortedMap where values are ordered by the given less function.
+func NewSortedMap[K comparable, V any](less func(v1, v2 V) bool) *SortedMap[K, V] {
+	return &SortedMap[K, V]{
+		less:  less,
+		m:     make(map[K]*node[K, V]),
+		head:  &node[K, V]{levels: make([]link[K, V], maxLevel)},
+		level: 1,
+	}
+}
+
+// NewSortedMapWithCompare returns a SortedMap where values are ordered by the
+// given three-way compare function, like cmp.Compare.
+func NewSortedMapWithCompare[K comparable, V any](compare func(v1, v2 V) int) *SortedMap[K, V] {
+	return NewSortedMap[K, V](func(v1, v2 V) bool {
+		return compare(v1, v2) < 0
+	})
+}
+
+// NewOrderedSortedMap returns a SortedMap where values are ordered by the
+// natural order of V, as defined by cmp.Less.
+func NewOrderedSortedMap[K comparable, V cmp.Ordered]() *SortedMap[K, V] {
+	return NewSortedMap[K, V](cmp.Less[V])
+}
+
+// Set inserts a k-v pair if the key does not exist. Otherwise, Set updates the value.
+// An updated pair is ordered after existing pairs of the same value.
+func (sm *SortedMap[K, V]) Set(k K, v V) {
+	if existing, ok := sm.m[k]; ok {
+		sm.remove(existing)
+		delete(sm.m, k)
+	}
+	sm.m[k] = sm.insert(k, v)
+}
+
+// Get returns the value associated with the key.
+func (sm *SortedMap[K, V]) Get(k K) (V, bool) {
+	n, ok := sm.m[k]
+	if !ok {
+		return sm.emptyV, false
+	}
+	return n.value, true
+}
+
+// Delete deletes the key-value pair of the key. It returns the deleted value and
+// true, or false if the key does not exist.
+func (sm *SortedMap[K, V]) Delete(k K) (V, bool) {
+	n, ok := sm.m[k]
+	if !ok {
+		return sm.emptyV, false
+	}
+	sm.remove(n)
+	delete(sm.m, k)
+	return n.value, true
+}
+
+// Top returns the key-value pair of the smallest value. It returns false
+// if the map is empty.
+func (sm *SortedMap[K, V]) Top() (K, V, bool) {
+	n := sm.head.levels[0].next
+	if n == nil {
+		return sm.emptyK, sm.emptyV, false
+	}
+	return n.key, n.value, true
+}
+
+// Pop removes and returns the key-value pair of the smallest value. It returns
+// false if the map is empty.
+func (sm *SortedMap[K, V]) Pop() (K, V, bool) {
+	n := sm.head.levels[0].next
+	if n == nil {
+		return sm.emptyK, sm.emptyV, false
+	}
+	sm.remove(n)
+	delete(sm.m, n.key)
+	return n.key, n.value, true
+}
+
+// Size returns the number of key-value pairs.
+func (sm *SortedMap[K, V]) Size() int {
+	return len(sm.m)
+}
+
+// Rank returns the 0-based rank of the key, i.e., the number of pairs ordered
+// before it. It returns false if the key does not exist.
+func (sm *SortedMap[K, V]) Rank(k K) (int, bool) {
+	target, ok := sm.m[k]
+	if !ok {
+		return 0, false
+	}
+	rank := 0
+	x := sm.head
+	for i := sm.level - 1; i >= 0; i-- {
+		for next := x.levels[i].next; next != nil && !sm.before(target, next); next = x.levels[i].next {
+			rank += x.levels[i].span
+			x = next
+		}
+		if x == target {
+			return rank - 1, true
+		}
+	}
+	return 0, false
+}
+
+// ByRank returns the key-value pair of the 0-based rank. It returns false if
+// the rank is out of [0, Size()).
+func (sm *SortedMap[K, V]) ByRank(rank int) (K, V, bool) {
+	n := sm.nodeByRank(rank)
+	if n == nil {
+		return sm.emptyK, sm.emptyV, false
+	}
+	return n.key, n.value, true
+}
+
+// RangeByRank returns key-value pairs of ranks in [start, end), in ascending
+// order of values. The range is clamped to [0, Size()).
+func (sm *SortedMap[K, V]) RangeByRank(start, end int) []Element[K, V] {
+	start, end = max(start, 0), min(end, sm.Size())
+	if start >= end {
+		return nil
+	}
+	result := make([]Element[K, V], 0, end-start)
+	for n := sm.nodeByRank(start); len(result) < end-start; n = n.levels[0].next {
+		result = append(result, Element[K, V]{Key: n.key, Value: n.value})
+	}
+	return result
+}
+
+// RangeByValue returns key-value pairs of values in [lo, hi], in ascending
+// order of values.
+func (sm *SortedMap[K, V]) RangeByValue(lo, hi V) []Element[K, V] {
+	x := sm.head
+	for i := sm.level - 1; i >= 0; i-- {
+		for next := x.levels[i].next; next != nil && sm.less(next.value, lo); next = x.levels[i].next {
+			x = next
+		}
+	}
+	var result []Element[K, V]
+	for n := x.levels[0].next; n != nil && !sm.less(hi, n.value); n = n.levels[0].next {
+		result = append(result, Element[K, V]{Key: n.key, Value: n.value})
+	}
+	return result
+}
+
+// Ascend returns an iterator over key-value pairs in ascending order of values.
+// The map must not be modified during the iteration.
+func (sm *SortedMap[K, V]) Ascend() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for n := sm.head.levels[0].next; n != nil; n = n.levels[0].next {
+			if !yield(n.key, n.value) {
+				return
+			}
+		}
+	}
+}
+
+// before returns true if node a is ordered before node b.
+func (sm *SortedMap[K, V]) before(a, b *node[K, V]) bool {
+	if sm.less(a.value, b.value) {
+		return true
+	}
+	if sm.less(b.value, a.value) {
+		return false
+	}
+	return a.seq < b.seq
+}
+
+// nodeByRank returns the node of the 0-based rank, or nil if the rank is out of range.
+func (sm *SortedMap[K, V]) nodeByRank(rank int) *node[K, V] {
+	if rank < 0 || rank >= sm.Size() {
+		return nil
+	}
+	// traversed counts nodes from head to x, i.e., the 1-based rank of x.
+	traversed := 0
+	x := sm.head
+	for i := sm.level - 1; i >= 0; i-- {
+		for x.levels[i].next != nil && traversed+x.levels[i].span <= rank+1 {
+			traversed += x.levels[i].span
+			x = x.levels[i].next
+		}
+		if traversed == rank+1 {
+			return x
+		}
+	}
+	return nil
+}
+
+// insert inserts a new node of the key-value pair into the skip list.
+func (sm *SortedMap[K, V]) insert(k K, v V) *node[K, V] {
+	n := &node[K, V]{key: k, value: v, seq: sm.seq}
+	sm.seq++
+
+	// update[i] is the last node before n at level i, and rank[i] is the
+	// 1-based rank of update[i].
+	var update [maxLevel]*node[K, V]
+	var rank [maxLevel]int
+	x := sm.head
+	for i := sm.level - 1; i >= 0; i-- {
+		if i < sm.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.levels[i].next != nil && sm.before(x.levels[i].next, n) {
+			rank[i] += x.levels[i].span
+			x = x.levels[i].next
+		}
+		update[i] = x
+	}
+
+	level := randomLevel()
+	if level > sm.level {
+		for i := sm.level; i < level; i++ {
+			update[i] = sm.head
+			sm.head.levels[i].span = len(sm.m)
+		}
+		sm.level = level
+	}
+
+	n.levels = make([]link[K, V], level)
+	for i := 0; i < level; i++ {
+		n.levels[i].next = update[i].levels[i].next
+		update[i].levels[i].next = n
+		// Nodes between update[i] and n at level i are the ones between
+		// update[i] and update[0], plus update[0] itself.
+		n.levels[i].span = update[i].levels[i].span - (rank[0] - rank[i])
+		update[i].levels[i].span = rank[0] - rank[i] + 1
+	}
+	// Links above the level of n skip over one more node.
+	for i := level; i < sm.level; i++ {
+		update[i].levels[i].span++
+	}
+	return n
+}
+
+// remove removes the node from the skip list. It does not touch the hash map.
+func (sm *SortedMap[K, V]) remove(n *node[K, V]) {
+	x := sm.head
+	for i := sm.level - 1; i >= 0; i-- {
+		for x.levels[i].next != nil && sm.before(x.levels[i].next, n) {
+			x = x.levels[i].next
+		}
+		// x is the last node before n at level i.
+		if x.levels[i].next == n {
+			x.levels[i].span += n.levels[i].span - 1
+			x.levels[i].next = n.levels[i].next
+		} else {
+			x.levels[i].span--
+		}
+	}
+	for sm.level > 1 && sm.head.levels[sm.level-1].next == nil {
+		sm.level--
+	}
+}
+
+// randomLevel returns a level in [1, maxLevel], where level l+1 is p times as
+// likely as level l.
+func randomLevel() int {
+	level := 1
+	for level < maxLevel && rand.Float64() < p {
+		level++
+	}
+	return level
+}
@@ -0,0 +1,196 @@
+package sorted_map_test
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/pengubco/algorithms/sorted_map"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortedMap_Simple_Pairs(t *testing.T) {
+	assert := assert.New(t)
+	sm := sorted_map.NewOrderedSortedMap[string, int]()
+	_, _, ok := sm.Top()
+	assert.False(ok)
+	_, _, ok = sm.Pop()
+	assert.False(ok)
+	_, ok = sm.Rank("a")
+	assert.False(ok)
+	_, _, ok = sm.ByRank(0)
+	assert.False(ok)
+	assert.Empty(sm.RangeByRank(0, 10))
+	assert.Empty(sm.RangeByValue(0, 10))
+
+	sm.Set("a", 30)
+	sm.Set("b", 10)
+	sm.Set("c", 20)
+	assert.Equal(3, sm.Size())
+	rank, ok := sm.Rank("a")
+	assert.True(ok)
+	assert.Equal(2, rank)
+	k, v, ok := sm.ByRank(0)
+	assert.True(ok)
+	assert.Equal("b", k)
+	assert.Equal(10, v)
+	assert.Equal([]sorted_map.Element[string, int]{{"c", 20}, {"a", 30}}, sm.RangeByValue(15, 30))
+	assert.Equal([]sorted_map.Element[string, int]{{"b", 10}, {"c", 20}}, sm.RangeByRank(-1, 2))
+
+	sm.Set("a", 0)
+	k, v, _ = sm.Top()
+	assert.Equal("a", k)
+	assert.Equal(0, v)
+
+	v, ok = sm.Delete("c")
+	assert.True(ok)
+	assert.Equal(20, v)
+	_, ok = sm.Delete("c")
+	assert.False(ok)
+
+	k, _, _ = sm.Pop()
+	assert.Equal("a", k)
+	k, _, _ = sm.Pop()
+	assert.Equal("b", k)
+	assert.Equal(0, sm.Size())
+}
+
+func TestSortedMap_DuplicateValues(t *testing.T) {
+	assert := assert.New(t)
+	sm := sorted_map.NewSortedMap[string, int](func(v1, v2 int) bool {
+		return v1 > v2
+	})
+	sm.Set("a", 10)
+	sm.Set("b", 20)
+	sm.Set("c", 10)
+	sm.Set("d", 20)
+	// Pairs of equal values are ordered by the time they are set.
+	assert.Equal([]sorted_map.Element[string, int]{{"b", 20}, {"d", 20}, {"a", 10}, {"c", 10}}, sm.RangeByRank(0, 4))
+	sm.Set("b", 20)
+	assert.Equal([]sorted_map.Element[string, int]{{"d", 20}, {"b", 20}}, sm.RangeByValue(20, 20))
+	rank, _ := sm.Rank("b")
+	assert.Equal(1, rank)
+}
+
+// pair is a key-value pair of the brute-force model of SortedMap.
+type pair struct {
+	key, value int
+	seq        int
+}
+
+// TestSortedMap_Random runs random operations on a SortedMap and a brute-force
+// sorted slice, and compares their results.
+func TestSortedMap_Random(t *testing.T) {
+	assert := assert.New(t)
+	r := rand.New(rand.NewSource(1))
+	sm := sorted_map.NewOrderedSortedMap[int, int]()
+	var model []pair
+	seq := 0
+
+	for i := 0; i < 5000; i++ {
+		k := r.Intn(500)
+		switch op := r.Intn(10); {
+		case op < 6:
+			v := r.Intn(100)
+			sm.Set(k, v)
+			model = slices.DeleteFunc(model, func(p pair) bool { return p.key == k })
+			model = append(model, pair{k, v, seq})
+			seq++
+		case op < 8:
+			v, ok := sm.Delete(k)
+			j := slices.IndexFunc(model, func(p pair) bool { return p.key == k })
+			assert.Equal(j >= 0, ok)
+			if j >= 0 {
+				assert.Equal(model[j].value, v)
+				model = slices.Delete(model, j, j+1)
+			}
+		default:
+			k, v, ok := sm.Pop()
+			assert.Equal(len(model) > 0, ok)
+			if len(model) > 0 {
+				slices.SortFunc(model, comparePairs)
+				assert.Equal(model[0].key, k)
+				assert.Equal(model[0].value, v)
+				model = model[1:]
+			}
+		}
+		slices.SortFunc(model, comparePairs)
+		assert.Equal(len(model), sm.Size())
+		if i%100 == 0 {
+			verifySortedMap(t, sm, model)
+		}
+	}
+	verifySortedMap(t, sm, model)
+}
+
+func comparePairs(a, b pair) int {
+	if a.value != b.value {
+		return a.value - b.value
+	}
+	return a.seq - b.seq
+}
+
+// verifySortedMap checks all queries of sm against the sorted model.
+func verifySortedMap(t *testing.T, sm *sorted_map.SortedMap[int, int], model []pair) {
+	assert := assert.New(t)
+	elements := make([]sorted_map.Element[int, int], len(model))
+	for i, p := range model {
+		elements[i] = sorted_map.Element[int, int]{Key: p.key, Value: p.value}
+		rank, ok := sm.Rank(p.key)
+		assert.True(ok)
+		assert.Equal(i, rank)
+		k, v, ok := sm.ByRank(i)
+		assert.True(ok)
+		assert.Equal(p.key, k)
+		assert.Equal(p.value, v)
+	}
+	_, _, ok := sm.ByRank(len(model))
+	assert.False(ok)
+
+	all := make([]sorted_map.Element[int, int], 0, len(model))
+	for k, v := range sm.Ascend() {
+		all = append(all, sorted_map.Element[int, int]{Key: k, Value: v})
+	}
+	assert.Equal(elements, all)
+
+	for _, r := range [][2]int{{0, 10}, {5, 17}, {len(model) - 3, len(model) + 3}, {7, 7}} {
+		expected := elements[min(max(r[0], 0), len(model)):min(max(r[1], 0), len(model))]
+		assert.Equal(fmt.Sprint(expected), fmt.Sprint(sm.RangeByRank(r[0], r[1])), "range %v", r)
+	}
+	for _, r := range [][2]int{{0, 99}, {10, 20}, {50, 50}, {30, 10}} {
+		var expected []sorted_map.Element[int, int]
+		for _, e := range elements {
+			if e.Value >= r[0] && e.Value <= r[1] {
+				expected = append(expected, e)
+			}
+		}
+		assert.Equal(fmt.Sprint(expected), fmt.Sprint(sm.RangeByValue(r[0], r[1])), "range %v", r)
+	}
+}
+
+// Add 1M key-value pairs in random values.
+func BenchmarkSortedMap_Add_1M(b *testing.B) {
+	n := 1_000_000
+	values := rand.Perm(n)
+	for i := 0; i < b.N; i++ {
+		sm := sorted_map.NewOrderedSortedMap[int, int]()
+		for j := 0; j < n; j++ {
+			sm.Set(j, values[j])
+		}
+	}
+}
+
+// Query ranks of 1M key-value pairs.
+func BenchmarkSortedMap_Rank_1M(b *testing.B) {
+	n := 1_000_000
+	values := rand.Perm(n)
+	sm := sorted_map.NewOrderedSortedMap[int, int]()
+	for j := 0; j < n; j++ {
+		sm.Set(j, values[j])
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sm.Rank(i % n)
+	}
+}